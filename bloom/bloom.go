@@ -0,0 +1,99 @@
+// bloom filter
+package bloom
+
+import (
+	"errors"
+	"hash/fnv"
+	"math"
+	"sync/atomic"
+)
+
+// Filter is a bloom filter, Add and Test are safe for concurrent use
+type Filter struct {
+	m    uint64 // 位数，64 的整数倍
+	k    uint32 // 哈希函数个数
+	bits []atomic.Uint64
+}
+
+// New creates a filter for about n keys with false positive rate p
+func New(n int, p float64) *Filter {
+	m, k := OptimalParams(n, p)
+	return &Filter{m: m, k: k, bits: make([]atomic.Uint64, m/64)}
+}
+
+// OptimalParams returns the number of bits and hash functions for n keys
+// and false positive rate p
+func OptimalParams(n int, p float64) (m uint64, k uint32) {
+	if n < 1 {
+		n = 1
+	}
+	if p <= 0 || p >= 1 {
+		p = 0.01
+	}
+	bits := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	m = (uint64(bits) + 63) / 64 * 64
+	k = uint32(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return m, k
+}
+
+// FromBits rebuilds a filter from the bits and k of another filter
+func FromBits(bits []uint64, k uint32) (*Filter, error) {
+	if len(bits) == 0 || k == 0 {
+		return nil, errors.New("bloom: empty filter")
+	}
+	f := &Filter{m: uint64(len(bits)) * 64, k: k, bits: make([]atomic.Uint64, len(bits))}
+	for i, b := range bits {
+		f.bits[i].Store(b)
+	}
+	return f, nil
+}
+
+// Add adds key to the filter
+func (f *Filter) Add(key string) {
+	h1, h2 := hashes(key)
+	for i := uint32(0); i < f.k; i++ {
+		pos := (h1 + uint64(i)*h2) % f.m
+		f.bits[pos/64].Or(1 << (pos % 64))
+	}
+}
+
+// Test reports whether key may be in the filter, false means it is definitely not
+func (f *Filter) Test(key string) bool {
+	h1, h2 := hashes(key)
+	for i := uint32(0); i < f.k; i++ {
+		pos := (h1 + uint64(i)*h2) % f.m
+		if f.bits[pos/64].Load()&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Bits returns a copy of the bits of the filter
+func (f *Filter) Bits() []uint64 {
+	bits := make([]uint64, len(f.bits))
+	for i := range f.bits {
+		bits[i] = f.bits[i].Load()
+	}
+	return bits
+}
+
+// K returns the number of hash functions
+func (f *Filter) K() uint32 {
+	return f.k
+}
+
+// hashes returns the two hashes used for double hashing, the result does not
+// depend on the process so that the bits can be shipped to other nodes
+func hashes(key string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	h1 := h.Sum64()
+	h = fnv.New64()
+	h.Write([]byte(key))
+	h2 := h.Sum64() | 1
+	return h1, h2
+}
@@ -0,0 +1,76 @@
+package bloom
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	n := 10000
+	f := New(n, 0.01)
+	for i := 0; i < n; i++ {
+		f.Add("key" + strconv.Itoa(i))
+	}
+	for i := 0; i < n; i++ {
+		if !f.Test("key" + strconv.Itoa(i)) {
+			t.Fatalf("false negative for key%d", i)
+		}
+	}
+
+	// 误判率应接近 1%
+	fp := 0
+	for i := 0; i < n; i++ {
+		if f.Test("missing" + strconv.Itoa(i)) {
+			fp++
+		}
+	}
+	if rate := float64(fp) / float64(n); rate > 0.02 {
+		t.Fatalf("false positive rate %v is too high", rate)
+	}
+}
+
+func TestOptimalParams(t *testing.T) {
+	m, k := OptimalParams(1000, 0.01)
+	// 约 9.6 bits/key，7 个哈希函数
+	if m < 9585 || m > 9585+64 || m%64 != 0 || k != 7 {
+		t.Fatalf("OptimalParams(1000, 0.01) = %d, %d", m, k)
+	}
+	if m, k := OptimalParams(0, 0); m == 0 || k == 0 {
+		t.Fatalf("expect defaults for invalid params, got %d, %d", m, k)
+	}
+}
+
+func TestFromBits(t *testing.T) {
+	f := New(100, 0.01)
+	f.Add("Tom")
+	g, err := FromBits(f.Bits(), f.K())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !g.Test("Tom") {
+		t.Fatal("expect the copy to contain Tom")
+	}
+	if _, err := FromBits(nil, 3); err == nil {
+		t.Fatal("expect error for empty bits")
+	}
+}
+
+func TestFilterConcurrency(t *testing.T) {
+	f := New(1000, 0.01)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := strconv.Itoa(i*100 + j)
+				f.Add(key)
+				if !f.Test(key) {
+					t.Errorf("false negative for %s", key)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
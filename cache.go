@@ -1,76 +1,347 @@
 package mygroupcache
 
 import (
+	"my_groupcache/logger"
 	"my_groupcache/lru"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	defaultEvictionInterval = 60 * time.Second
+	// 自动选择分片数时，每个分片至少分到的字节数
+	minShardBytes = 64 << 10
+)
+
 type cache struct {
-	// maxBytes
+	// maxBytes，由所有分片平分
 	maxBytes int
+	// 分片数，会向上取整到 2 的幂，0 根据 GOMAXPROCS 和 maxBytes 自动选择
+	shards int
 	// k
 	k int
-	// lru-k cache
-	lru *lru.Cache
-	// lock
+	// 淘汰策略，默认 LRU-K
+	policy lru.PolicyType
+	// 默认过期时间，0 使用 lru 的默认值，NoExpiration 表示不过期
+	ttl time.Duration
+	// 过期时间的随机抖动，避免同时加载的数据同时过期
+	jitter lru.Jitter
+	// 过期后仍可通过 getStale 读到旧值的时间
+	grace time.Duration
+	// 热点层数据剩余过期时间小于 refreshAhead*ttl 时被访问，调用 onRefresh 提前刷新
+	refreshAhead float64
+	onRefresh    func(key string) bool
+	// 后台清理过期数据的间隔，0 使用 defaultEvictionInterval
+	evictionInterval time.Duration
+	// 数据被淘汰、过期或删除时的回调，调用时持有所在分片的锁
+	onEvicted func(key string, value ByteView)
+	// 为空时不输出日志
+	logger logger.Logger
+	// 每个分片读缓冲区的大小，0 表示命中时直接加写锁更新淘汰策略
+	readBuffer int
+	// 第一次写入时创建，之后不再改变
+	shardList atomic.Pointer[[]*cacheShard]
+	// lock, 保护分片的创建和后台清理的状态
 	mu sync.Mutex
 	// stop
 	stopChan chan struct{}
 	// running
 	evictionRunning bool
+	// closed, no eviction loop will be started any more
+	closed bool
+}
+
+// cacheShard is a part of the cache with its own lock and byte budget
+type cacheShard struct {
+	// 开启读缓冲区时命中只加读锁，否则总是加写锁
+	mu sync.RWMutex
+	// lru-k cache，或 policy 指定的其他淘汰策略
+	lru lru.Store
+	// 还没应用到 lru 的访问记录，为空时不缓冲
+	reads *lru.ReadBuffer
+	// 统计信息，不加锁也可以读取
+	nget atomic.Int64
+	nhit atomic.Int64
+}
+
+// add adds a value that expires after ttl, NoExpiration means never expire
+// and any other ttl <= 0 uses the default expire time of the cache
+func (c *cache) add(key string, value ByteView, ttl time.Duration) {
+	s := c.shard(key, true)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// 先应用之前的访问，最近命中的数据不会被这次写入淘汰
+	s.drainLocked()
+	switch {
+	case ttl > 0:
+		s.lru.AddWithExpire(key, value, ttl)
+	case ttl == NoExpiration:
+		s.lru.AddWithExpire(key, value, 0)
+	default:
+		s.lru.Add(key, value)
+	}
+}
+
+// remove, reports whether the key was cached
+func (c *cache) remove(key string) bool {
+	s := c.shard(key, false)
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Remove(key)
+}
+
+// shard returns the shard of key, the shards are created if create is set,
+// otherwise nil is returned until they exist
+func (c *cache) shard(key string, create bool) *cacheShard {
+	shards := c.shardList.Load()
+	if shards == nil {
+		if !create {
+			return nil
+		}
+		shards = c.lazyInit()
+	}
+	list := *shards
+	return list[shardHash(key)&uint32(len(list)-1)]
+}
+
+// shardHash is FNV-1a, it does not allocate
+func shardHash(key string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return h
+}
+
+// shardCount rounds the configured number of shards up to a power of two,
+// capped so that every shard gets at least one byte. 0 picks about one shard
+// per CPU while every shard gets at least minShardBytes
+func (c *cache) shardCount() int {
+	count := 1
+	if c.shards > 0 {
+		for count < c.shards {
+			count <<= 1
+		}
+		// 0 字节的分片在 lru 中表示不限大小
+		for c.maxBytes > 0 && count > c.maxBytes {
+			count >>= 1
+		}
+		return count
+	}
+	n := runtime.GOMAXPROCS(0)
+	if c.maxBytes > 0 && c.maxBytes/minShardBytes < n {
+		n = c.maxBytes / minShardBytes
+	}
+	// 向下取整，保证每个分片的大小
+	for count*2 <= n {
+		count <<= 1
+	}
+	return count
 }
 
-// add
-func (c *cache) add(key string, value ByteView) {
+// lazyInit creates the shards and starts the eviction loop
+func (c *cache) lazyInit() *[]*cacheShard {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if c.lru == nil {
-		c.lru = lru.NewCache(c.k, c.maxBytes, nil)
-		go c.startEvictionLoop(60 * time.Second)
+	if shards := c.shardList.Load(); shards != nil {
+		return shards
 	}
-	c.lru.Add(key, value)
+	var onEvicted func(string, lru.Value)
+	if c.onEvicted != nil {
+		onEvicted = func(key string, value lru.Value) {
+			c.onEvicted(key, value.(ByteView))
+		}
+	}
+	n := c.shardCount()
+	shards := make([]*cacheShard, n)
+	for i := range shards {
+		// 平分字节数，余数分给前面的分片
+		maxBytes := 0
+		if c.maxBytes > 0 {
+			maxBytes = c.maxBytes / n
+			if i < c.maxBytes%n {
+				maxBytes++
+			}
+		}
+		store := lru.New(c.policy, c.k, int64(maxBytes), onEvicted)
+		if c.ttl == NoExpiration {
+			store.SetExpireTime(0)
+		} else if c.ttl > 0 {
+			store.SetExpireTime(c.ttl)
+		}
+		store.SetJitter(c.jitter)
+		store.SetGrace(c.grace)
+		store.SetLogger(c.logger)
+		if c.onRefresh != nil {
+			store.SetRefreshAhead(c.refreshAhead, c.onRefresh)
+		}
+		shards[i] = &cacheShard{lru: store}
+		if c.readBuffer > 0 {
+			shards[i].reads = lru.NewReadBuffer(c.readBuffer)
+		}
+	}
+	c.shardList.Store(&shards)
+	if !c.closed {
+		interval := c.evictionInterval
+		if interval <= 0 {
+			interval = defaultEvictionInterval
+		}
+		c.startEvictionLoopLocked(interval)
+	}
+	return &shards
 }
 
 // get
 func (c *cache) get(key string) (byteview ByteView, ok bool) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if c.lru == nil {
-		return
-	}
-	if value, ok := c.lru.Get(key); ok {
+	if value, _, ok := c.lookup(key, false); ok {
 		return value.(ByteView), ok
 	}
 	return
 }
 
+// getStale is like get, but also returns entries expired less than grace ago
+func (c *cache) getStale(key string) (byteview ByteView, stale bool, ok bool) {
+	if value, stale, ok := c.lookup(key, true); ok {
+		return value.(ByteView), stale, ok
+	}
+	return
+}
+
+// lookup finds key in its shard, stale entries are only returned if allowStale
+func (c *cache) lookup(key string, allowStale bool) (value lru.Value, stale bool, ok bool) {
+	// 先创建分片，让没有数据时的 get 也计入统计
+	s := c.shard(key, true)
+	s.nget.Add(1)
+	if s.reads != nil {
+		value, stale, ok = s.peek(key)
+	} else {
+		s.mu.Lock()
+		value, stale, ok = s.lru.GetStale(key)
+		s.mu.Unlock()
+	}
+	if !ok || (stale && !allowStale) {
+		return nil, false, false
+	}
+	s.nhit.Add(1)
+	return value, stale, ok
+}
+
+// peek looks up key under the read lock and records the hit in the read
+// buffer, the buffer is drained by whoever gets the write lock first
+func (s *cacheShard) peek(key string) (value lru.Value, stale bool, ok bool) {
+	s.mu.RLock()
+	h, stale, ok := s.lru.Peek(key)
+	if ok {
+		value = h.Value()
+	}
+	s.mu.RUnlock()
+	if ok && s.reads.Record(h) && s.mu.TryLock() {
+		s.drainLocked()
+		s.mu.Unlock()
+	}
+	return
+}
+
+// drainLocked applies the buffered hits to the eviction policy, s.mu must be held
+func (s *cacheShard) drainLocked() {
+	if s.reads != nil {
+		s.reads.Drain(s.lru)
+	}
+}
+
+// lruStats returns the stats of each tier summed over the shards,
+// it can be called without holding any lock
+func (c *cache) lruStats() lru.Stats {
+	var ls lru.Stats
+	shards := c.shardList.Load()
+	if shards == nil {
+		return ls
+	}
+	for _, s := range *shards {
+		st := s.lru.Stats()
+		ls.History = addTierStats(ls.History, st.History)
+		ls.Hot = addTierStats(ls.Hot, st.Hot)
+		ls.Promotions += st.Promotions
+	}
+	return ls
+}
+
+func addTierStats(a, b lru.TierStats) lru.TierStats {
+	return lru.TierStats{
+		Bytes:       a.Bytes + b.Bytes,
+		Items:       a.Items + b.Items,
+		Evictions:   a.Evictions + b.Evictions,
+		Expirations: a.Expirations + b.Expirations,
+	}
+}
+
+// stats can be called without holding any lock
+func (c *cache) stats() CacheStats {
+	var s CacheStats
+	shards := c.shardList.Load()
+	if shards == nil {
+		return s
+	}
+	for _, sh := range *shards {
+		s.Gets += sh.nget.Load()
+		s.Hits += sh.nhit.Load()
+	}
+	ls := c.lruStats()
+	s.Bytes = ls.Bytes()
+	s.Items = ls.Items()
+	s.Evictions = ls.History.Evictions + ls.Hot.Evictions
+	s.Expirations = ls.History.Expirations + ls.Hot.Expirations
+	s.Promotions = ls.Promotions
+	return s
+}
+
 func (c *cache) startEvictionLoop(interval time.Duration) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.startEvictionLoopLocked(interval)
+}
+
+// startEvictionLoopLocked must be called with c.mu held
+func (c *cache) startEvictionLoopLocked(interval time.Duration) {
 	if c.evictionRunning {
 		return
 	}
 	c.evictionRunning = true
 	c.stopChan = make(chan struct{})
+	stop := c.stopChan
 	go func() {
 		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 		for {
 			select {
 			case <-ticker.C:
-				c.mu.Lock()
-				if c.lru != nil {
-					c.lru.CleanExpired()
-				}
-				c.mu.Unlock()
-			case <-c.stopChan:
+				c.cleanExpired()
+			case <-stop:
 				return
 			}
 		}
 	}()
 }
 
+// cleanExpired removes the expired entries shard by shard
+func (c *cache) cleanExpired() {
+	shards := c.shardList.Load()
+	if shards == nil {
+		return
+	}
+	for _, s := range *shards {
+		s.mu.Lock()
+		s.drainLocked()
+		s.lru.CleanExpired()
+		s.mu.Unlock()
+	}
+}
 
 func (c *cache) stopEvictionLoop() {
 	c.mu.Lock()
@@ -80,4 +351,12 @@ func (c *cache) stopEvictionLoop() {
 		c.stopChan = nil
 		c.evictionRunning = false
 	}
-}
\ No newline at end of file
+}
+
+// close stops the eviction loop for good
+func (c *cache) close() {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	c.stopEvictionLoop()
+}
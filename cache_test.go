@@ -2,6 +2,8 @@
 package mygroupcache
 
 import (
+	"fmt"
+	"my_groupcache/lru"
 	"testing"
 	"strconv"
 	"sync"
@@ -18,7 +20,7 @@ func TestCacheAddAndGet(t *testing.T) {
 	val := ByteView{b : []byte("testValue")}
 
 	// Add key
-	c.add(key, val)
+	c.add(key, val, 0)
 
 	// First Get (from history)
 	v, ok := c.get(key)
@@ -49,13 +51,13 @@ func TestCacheUpdate(t *testing.T) {
 	val1 := ByteView{[]byte("val1")}
 	val2 := ByteView{[]byte("val2")}
 
-	c.add(key, val1)
+	c.add(key, val1, 0)
 	v, _ := c.get(key)
 	if string(v.ByteSlice()) != "val1" {
 		t.Fatalf("expected val1, got %s", v.ByteSlice())
 	}
 
-	c.add(key, val2)
+	c.add(key, val2, 0)
 	v, _ = c.get(key)
 	if string(v.ByteSlice()) != "val2" {
 		t.Fatalf("expected val2 after update, got %s", v.ByteSlice())
@@ -78,7 +80,7 @@ func TestCacheConcurrency(t *testing.T) {
 			defer wg.Done()
 			key := "key" + strconv.Itoa(i)
 			val := ByteView{[]byte("val" + strconv.Itoa(i))}
-			c.add(key, val)
+			c.add(key, val, 0)
 		}(i)
 	}
 
@@ -119,8 +121,8 @@ func TestEvictionLoop(t *testing.T) {
 		k:        2,
 	}
 
-	c.add("key1", ByteView{b: []byte("123")})
-	c.add("key2", ByteView{b: []byte("456")})
+	c.add("key1", ByteView{b: []byte("123")}, 0)
+	c.add("key2", ByteView{b: []byte("456")}, 0)
 
 	if val, ok := c.get("key1"); !ok || string(val.ByteSlice()) != "123" {
 		t.Fatalf("val should be 123 but be %v", val)
@@ -141,3 +143,239 @@ func TestEvictionLoop(t *testing.T) {
 	t.Log("eviction loop ran safely")
 }
 
+
+func TestCacheClose(t *testing.T) {
+	c := &cache{maxBytes: 1024}
+	c.add("key1", ByteView{b: []byte("123")}, 0)
+	c.close()
+	if c.evictionRunning {
+		t.Fatal("close should stop the eviction loop")
+	}
+
+	c = &cache{maxBytes: 1024}
+	c.close()
+	c.add("key1", ByteView{b: []byte("123")}, 0)
+	if c.evictionRunning {
+		t.Fatal("a closed cache should not start the eviction loop")
+	}
+}
+
+func TestCacheShards(t *testing.T) {
+	c := &cache{maxBytes: 1000, shards: 3, k: 1}
+	for i := 0; i < 100; i++ {
+		c.add("key"+strconv.Itoa(i), ByteView{b: []byte("v")}, 0)
+	}
+	// 分片数向上取整到 2 的幂，字节数平分
+	shards := *c.shardList.Load()
+	if len(shards) != 4 {
+		t.Fatalf("got %d shards, want 4", len(shards))
+	}
+	var items int64
+	for i, s := range shards {
+		st := s.lru.Stats()
+		if st.Items() == 0 {
+			t.Fatalf("shard %d is empty, keys should be spread", i)
+		}
+		items += st.Items()
+	}
+	for i := 0; i < 100; i++ {
+		c.get("key" + strconv.Itoa(i))
+	}
+	c.get("missing")
+	st := c.stats()
+	if st.Items != items || st.Items != 100 || st.Gets != 101 || st.Hits != 100 {
+		t.Fatalf("stats() = %+v, want the sum of the shards", st)
+	}
+
+	// 每个分片只能用 1/4 的字节
+	c = &cache{maxBytes: 400, shards: 4, k: 1}
+	for i := 0; i < 100; i++ {
+		c.add(fmt.Sprintf("key%02d", i), ByteView{b: []byte("value")}, 0)
+	}
+	for i, s := range *c.shardList.Load() {
+		if b := s.lru.Stats().Bytes(); b > 100 {
+			t.Fatalf("shard %d holds %d bytes, over its budget of 100", i, b)
+		}
+	}
+}
+
+func TestCacheShardCount(t *testing.T) {
+	tests := []struct {
+		maxBytes, shards, want int
+	}{
+		{1 << 20, 1, 1},
+		{1 << 20, 5, 8},
+		{1024, 0, 1}, // 太小的缓存不分片
+		{10, 16, 8},  // 每个分片至少 1 字节
+		{1, 4, 1},
+	}
+	for _, tt := range tests {
+		c := &cache{maxBytes: tt.maxBytes, shards: tt.shards}
+		if got := c.shardCount(); got != tt.want {
+			t.Errorf("shardCount(%d, %d) = %d, want %d", tt.maxBytes, tt.shards, got, tt.want)
+		}
+	}
+	// 分片多于字节数时也不能超出总大小
+	small := &cache{maxBytes: 10, shards: 16, k: 1}
+	for i := 0; i < 100; i++ {
+		small.add("key"+strconv.Itoa(i), ByteView{b: []byte("v")}, 0)
+	}
+	if b := small.stats().Bytes; b > 10 {
+		t.Errorf("a cache of 10 bytes with 16 shards holds %d bytes", b)
+	}
+	small.close()
+
+	// 自动选择时每个分片至少 minShardBytes
+	c := &cache{maxBytes: 3 * minShardBytes}
+	if got := c.shardCount(); got > 2 {
+		t.Errorf("shardCount(%d, 0) = %d, shards would be smaller than %d bytes", c.maxBytes, got, minShardBytes)
+	}
+}
+
+func TestCacheReadBuffer(t *testing.T) {
+	c := &cache{maxBytes: 1024, k: 2, readBuffer: 64}
+	defer c.close()
+	c.add("Tom", ByteView{b: []byte("630")}, 0)
+	for i := 0; i < 3; i++ {
+		if v, ok := c.get("Tom"); !ok || v.String() != "630" {
+			t.Fatalf("get(Tom) = %v, %v", v, ok)
+		}
+	}
+	// 命中先记在缓冲区里，写入时才晋升到热点层
+	if st := c.stats(); st.Hits != 3 || st.Promotions != 0 {
+		t.Fatalf("stats() = %+v, want 3 hits and no promotion yet", st)
+	}
+	c.add("Jack", ByteView{b: []byte("589")}, 0)
+	if st := c.stats(); st.Promotions != 1 {
+		t.Fatalf("stats() = %+v, buffered hits should promote Tom", st)
+	}
+
+	// 缓冲的访问同样保护最近用过的数据
+	c = &cache{maxBytes: 4, shards: 1, policy: lru.PolicyLRU, readBuffer: 64}
+	defer c.close()
+	c.add("a", ByteView{b: []byte("1")}, NoExpiration)
+	c.add("b", ByteView{b: []byte("1")}, NoExpiration)
+	c.get("a")
+	c.add("c", ByteView{b: []byte("1")}, NoExpiration)
+	if _, ok := c.get("a"); !ok {
+		t.Fatal("a was hit before c was added and should not be evicted")
+	}
+	if _, ok := c.get("b"); ok {
+		t.Fatal("b should be evicted")
+	}
+}
+
+func TestCacheReadBufferConcurrency(t *testing.T) {
+	c := &cache{maxBytes: 1 << 10, shards: 4, k: 2, readBuffer: 16}
+	defer c.close()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 2000; j++ {
+				key := "key" + strconv.Itoa(j%100)
+				if j%10 == i {
+					c.add(key, ByteView{b: []byte("value")}, 0)
+				} else {
+					c.get(key)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if st := c.stats(); st.Bytes > 1<<10 || st.Hits == 0 {
+		t.Fatalf("stats() = %+v", st)
+	}
+}
+
+// benchKeys are allocated once, so that the benchmarks only measure the cache
+var benchKeys = func() []string {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+	}
+	return keys
+}()
+
+// benchCaches are the configurations compared by the parallel benchmarks
+var benchCaches = []struct {
+	name               string
+	shards, readBuffer int
+}{
+	{"shards=1", 1, 0},
+	{"shards=16", 16, 0},
+	{"shards=1/readbuffer", 1, 64},
+	{"shards=16/readbuffer", 16, 64},
+}
+
+// BenchmarkCacheGetParallel reads hot keys from every goroutine, run it with
+// -cpu 1,2,4,8 to see how a single lock limits the throughput
+func BenchmarkCacheGetParallel(b *testing.B) {
+	for _, bc := range benchCaches {
+		b.Run(bc.name, func(b *testing.B) {
+			c := &cache{maxBytes: 64 << 20, shards: bc.shards, k: 1, readBuffer: bc.readBuffer}
+			defer c.close()
+			for _, key := range benchKeys {
+				c.add(key, ByteView{b: []byte("value")}, NoExpiration)
+			}
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					c.get(benchKeys[i%len(benchKeys)])
+					i++
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkCacheMixedParallel is like BenchmarkCacheGetParallel with one add every ten gets
+func BenchmarkCacheMixedParallel(b *testing.B) {
+	for _, bc := range benchCaches {
+		b.Run(bc.name, func(b *testing.B) {
+			c := &cache{maxBytes: 64 << 20, shards: bc.shards, k: 1, readBuffer: bc.readBuffer}
+			defer c.close()
+			value := ByteView{b: []byte("value")}
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					key := benchKeys[i%len(benchKeys)]
+					if i%10 == 0 {
+						c.add(key, value, NoExpiration)
+					} else {
+						c.get(key)
+					}
+					i++
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkCacheGetContended runs 8 goroutines per CPU against a few hot keys
+// of one shard, where every get of the locking path waits for the others and
+// the read buffer path only takes the read lock
+func BenchmarkCacheGetContended(b *testing.B) {
+	for _, readBuffer := range []int{0, 64} {
+		b.Run("readbuffer="+strconv.Itoa(readBuffer), func(b *testing.B) {
+			c := &cache{maxBytes: 64 << 20, shards: 1, k: 1, readBuffer: readBuffer}
+			defer c.close()
+			keys := benchKeys[:16]
+			for _, key := range keys {
+				c.add(key, ByteView{b: []byte("value")}, NoExpiration)
+			}
+			b.SetParallelism(8)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					c.get(keys[i%len(keys)])
+					i++
+				}
+			})
+		})
+	}
+}
@@ -23,8 +23,9 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type CacheServiceClient interface {
 	Get(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
-	Set(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*Response, error)
 	Delete(ctx context.Context, in *Request, opts ...grpc.CallOption) (*ResponseForDelete, error)
+	GetBloomFilter(ctx context.Context, in *BloomRequest, opts ...grpc.CallOption) (*BloomFilter, error)
 }
 
 type cacheServiceClient struct {
@@ -44,7 +45,7 @@ func (c *cacheServiceClient) Get(ctx context.Context, in *Request, opts ...grpc.
 	return out, nil
 }
 
-func (c *cacheServiceClient) Set(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+func (c *cacheServiceClient) Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*Response, error) {
 	out := new(Response)
 	err := c.cc.Invoke(ctx, "/cachepb.CacheService/Set", in, out, opts...)
 	if err != nil {
@@ -62,13 +63,23 @@ func (c *cacheServiceClient) Delete(ctx context.Context, in *Request, opts ...gr
 	return out, nil
 }
 
+func (c *cacheServiceClient) GetBloomFilter(ctx context.Context, in *BloomRequest, opts ...grpc.CallOption) (*BloomFilter, error) {
+	out := new(BloomFilter)
+	err := c.cc.Invoke(ctx, "/cachepb.CacheService/GetBloomFilter", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // CacheServiceServer is the server API for CacheService service.
 // All implementations must embed UnimplementedCacheServiceServer
 // for forward compatibility
 type CacheServiceServer interface {
 	Get(context.Context, *Request) (*Response, error)
-	Set(context.Context, *Request) (*Response, error)
+	Set(context.Context, *SetRequest) (*Response, error)
 	Delete(context.Context, *Request) (*ResponseForDelete, error)
+	GetBloomFilter(context.Context, *BloomRequest) (*BloomFilter, error)
 	mustEmbedUnimplementedCacheServiceServer()
 }
 
@@ -79,12 +90,15 @@ type UnimplementedCacheServiceServer struct {
 func (UnimplementedCacheServiceServer) Get(context.Context, *Request) (*Response, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
 }
-func (UnimplementedCacheServiceServer) Set(context.Context, *Request) (*Response, error) {
+func (UnimplementedCacheServiceServer) Set(context.Context, *SetRequest) (*Response, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Set not implemented")
 }
 func (UnimplementedCacheServiceServer) Delete(context.Context, *Request) (*ResponseForDelete, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
 }
+func (UnimplementedCacheServiceServer) GetBloomFilter(context.Context, *BloomRequest) (*BloomFilter, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBloomFilter not implemented")
+}
 func (UnimplementedCacheServiceServer) mustEmbedUnimplementedCacheServiceServer() {}
 
 // UnsafeCacheServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -117,7 +131,7 @@ func _CacheService_Get_Handler(srv interface{}, ctx context.Context, dec func(in
 }
 
 func _CacheService_Set_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(Request)
+	in := new(SetRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
@@ -129,7 +143,7 @@ func _CacheService_Set_Handler(srv interface{}, ctx context.Context, dec func(in
 		FullMethod: "/cachepb.CacheService/Set",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(CacheServiceServer).Set(ctx, req.(*Request))
+		return srv.(CacheServiceServer).Set(ctx, req.(*SetRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
@@ -152,6 +166,24 @@ func _CacheService_Delete_Handler(srv interface{}, ctx context.Context, dec func
 	return interceptor(ctx, in, info, handler)
 }
 
+func _CacheService_GetBloomFilter_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BloomRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CacheServiceServer).GetBloomFilter(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cachepb.CacheService/GetBloomFilter",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CacheServiceServer).GetBloomFilter(ctx, req.(*BloomRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // CacheService_ServiceDesc is the grpc.ServiceDesc for CacheService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -171,6 +203,10 @@ var CacheService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Delete",
 			Handler:    _CacheService_Delete_Handler,
 		},
+		{
+			MethodName: "GetBloomFilter",
+			Handler:    _CacheService_GetBloomFilter_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "cache.proto",
@@ -2,60 +2,126 @@ package mygroupcache
 
 import (
 	"context"
-	"fmt"
 	pb "my_groupcache/cachepb"
-	"my_groupcache/registry"
-	clientv3 "go.etcd.io/etcd/client/v3"
-	"sync"
 	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 // client 只有一个字段——对等节点的地址，同时实现了 ProtoGetter 接口
 type client struct {
-	name       string // 格式：groupcache/127.0.0.1:8001
-	grpcClient pb.CacheServiceClient
-	clientOnce sync.Once
+	name  string       // 格式：groupcache/127.0.0.1:8001
+	addr  string       // 格式：127.0.0.1:8001
+	conns *connManager // 连接由 pool 统一管理
+	metrics *peerMetrics // 记录请求耗时，为空时不记录
 }
 
-var (
-	defaultEtcdConfig = clientv3.Config{
-		Endpoints:   []string{"localhost:2379"},
-		DialTimeout: 5 * time.Second,
+var _ BloomFetcher = (*client)(nil)
+
+// 调用方没有设置 deadline 时，对等节点请求的默认超时时间
+const defaultPeerTimeout = 5 * time.Second
+
+// grpcClient returns a client on the shared connection to the peer,
+// release must be called once the request is done
+func (c *client) grpcClient() (grpcClient pb.CacheServiceClient, release func(), err error) {
+	conn, release, err := c.conns.acquire(c.addr)
+	if err != nil {
+		return nil, nil, err
 	}
-)
+	return pb.NewCacheServiceClient(conn), release, nil
+}
+
+// defaultDial 直接连接对等节点，地址由服务发现提供
+func defaultDial(addr string) (*grpc.ClientConn, error) {
+	return grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+}
+
+// Get 方法，实现 ProtoGetter 接口
+func (c *client) Get(ctx context.Context, in *pb.Request, out *pb.Response) (err error) {
+	grpcClient, release, err := c.grpcClient()
+	if err != nil {
+		return err
+	}
+	defer release()
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := grpcClient.Get(ctx, in)
+	c.metrics.observe(c.addr, "Get", time.Since(start), err)
+	if err != nil {
+		return fromStatus(err)
+	}
+	out.Value = resp.GetValue()
+	return nil
+}
 
-func (c *client) initGrpcClient() {
-	cli, err := clientv3.New(defaultEtcdConfig)
+// Set 方法，将值写入拥有该 key 的节点
+func (c *client) Set(ctx context.Context, in *pb.SetRequest, out *pb.Response) error {
+	grpcClient, release, err := c.grpcClient()
 	if err != nil {
-		panic("create etcd client failed: " + err.Error())
+		return err
 	}
-	defer cli.Close() // 关闭 cli 释放资源，且不影响 gRPC服务
+	defer release()
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
 
-	// 测试是否能从etcd中Get到key
-	//resp, err := cli.Get(context.Background(), c.name)
-	//if err != nil {
-	//	fmt.Printf("get from etcd failed, err:%v\n", err)
-	//}
-	//for _, ev := range resp.Kvs {
-	//	fmt.Printf("%s---%s\n", ev.Key, ev.Value)
-	//}
+	start := time.Now()
+	resp, err := grpcClient.Set(ctx, in)
+	c.metrics.observe(c.addr, "Set", time.Since(start), err)
+	if err != nil {
+		return fromStatus(err)
+	}
+	out.Value = resp.GetValue()
+	return nil
+}
 
-	conn, err := registry.EtcdDial(cli, c.name)
+// Delete 方法，删除拥有该 key 的节点上的缓存
+func (c *client) Delete(ctx context.Context, in *pb.Request, out *pb.ResponseForDelete) error {
+	grpcClient, release, err := c.grpcClient()
 	if err != nil {
-		panic("etcd dial failed: " + err.Error())
+		return err
 	}
-	c.grpcClient = pb.NewCacheServiceClient(conn)
+	defer release()
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := grpcClient.Delete(ctx, in)
+	c.metrics.observe(c.addr, "Delete", time.Since(start), err)
+	if err != nil {
+		return fromStatus(err)
+	}
+	out.Value = resp.GetValue()
+	return nil
 }
 
-// Get 方法，实现 ProtoGetter 接口
-func (c *client) Get(in *pb.Request, out *pb.Response) (err error) {
-	c.clientOnce.Do(c.initGrpcClient)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+// withDefaultTimeout keeps the caller's deadline, or applies defaultPeerTimeout if there is none
+func withDefaultTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, defaultPeerTimeout)
+}
+
+// GetBloomFilter 方法，获取对等节点上 group 的布隆过滤器
+func (c *client) GetBloomFilter(ctx context.Context, in *pb.BloomRequest, out *pb.BloomFilter) error {
+	grpcClient, release, err := c.grpcClient()
+	if err != nil {
+		return err
+	}
+	defer release()
+	ctx, cancel := withDefaultTimeout(ctx)
 	defer cancel()
 
-	out, err = c.grpcClient.Get(ctx, in)
+	start := time.Now()
+	resp, err := grpcClient.GetBloomFilter(ctx, in)
+	c.metrics.observe(c.addr, "GetBloomFilter", time.Since(start), err)
 	if err != nil {
-		return fmt.Errorf("grpc client Get() error: %v", err)
+		return fromStatus(err)
 	}
+	out.Bits = resp.GetBits()
+	out.K = resp.GetK()
 	return nil
 }
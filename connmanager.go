@@ -0,0 +1,129 @@
+package mygroupcache
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// 拨号失败后的退避时间
+const (
+	minDialBackoff = 100 * time.Millisecond
+	maxDialBackoff = 10 * time.Second
+)
+
+// connManager keeps one grpc connection per peer, shared by every group of a pool.
+// Connections are dialed on first use, a failed dial is retried with exponential
+// backoff, and grpc itself reconnects an established connection if the peer restarts.
+type connManager struct {
+	dial func(addr string) (*grpc.ClientConn, error)
+
+	mu     sync.Mutex
+	conns  map[string]*peerConn
+	closed bool
+}
+
+type peerConn struct {
+	conn     *grpc.ClientConn
+	refs     int       // 正在使用该连接的请求数
+	removed  bool      // 节点已离开哈希环，最后一个请求结束后关闭连接
+	failures int       // 连续拨号失败次数
+	nextDial time.Time // 退避结束前不再拨号
+	lastErr  error
+}
+
+func newConnManager(dial func(addr string) (*grpc.ClientConn, error)) *connManager {
+	return &connManager{
+		dial:  dial,
+		conns: make(map[string]*peerConn),
+	}
+}
+
+// acquire returns the connection to addr, dialing it if needed.
+// release must be called once the request on it is done.
+func (m *connManager) acquire(addr string) (conn *grpc.ClientConn, release func(), err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, nil, fmt.Errorf("%w: connections closed", ErrPeerUnavailable)
+	}
+	pc, ok := m.conns[addr]
+	if !ok {
+		pc = &peerConn{}
+		m.conns[addr] = pc
+	}
+	if pc.conn == nil || pc.conn.GetState() == connectivity.Shutdown {
+		pc.conn = nil
+		now := time.Now()
+		if now.Before(pc.nextDial) {
+			return nil, nil, fmt.Errorf("%w: dial %s: %v", ErrPeerUnavailable, addr, pc.lastErr)
+		}
+		conn, err := m.dial(addr)
+		if err != nil {
+			pc.failures++
+			pc.lastErr = err
+			pc.nextDial = now.Add(dialBackoff(pc.failures))
+			return nil, nil, fmt.Errorf("%w: dial %s: %v", ErrPeerUnavailable, addr, err)
+		}
+		pc.conn = conn
+		pc.failures = 0
+		pc.lastErr = nil
+	}
+	pc.refs++
+	return pc.conn, func() { m.release(pc) }, nil
+}
+
+func (m *connManager) release(pc *peerConn) {
+	m.mu.Lock()
+	pc.refs--
+	closeNow := pc.removed && pc.refs == 0
+	m.mu.Unlock()
+	if closeNow && pc.conn != nil {
+		pc.conn.Close()
+	}
+}
+
+// remove closes the connection to a peer that left the ring,
+// requests still running on it are allowed to finish first
+func (m *connManager) remove(addr string) {
+	m.mu.Lock()
+	pc, ok := m.conns[addr]
+	delete(m.conns, addr)
+	if ok {
+		pc.removed = true
+	}
+	closeNow := ok && pc.refs == 0
+	m.mu.Unlock()
+	if closeNow && pc.conn != nil {
+		pc.conn.Close()
+	}
+}
+
+// close closes every connection, get fails afterwards
+func (m *connManager) close() {
+	m.mu.Lock()
+	conns := m.conns
+	m.conns = make(map[string]*peerConn)
+	m.closed = true
+	m.mu.Unlock()
+	for _, pc := range conns {
+		if pc.conn != nil {
+			pc.conn.Close()
+		}
+	}
+}
+
+// dialBackoff doubles from minDialBackoff up to maxDialBackoff
+func dialBackoff(failures int) time.Duration {
+	backoff := minDialBackoff
+	for i := 1; i < failures && backoff < maxDialBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxDialBackoff {
+		backoff = maxDialBackoff
+	}
+	return backoff
+}
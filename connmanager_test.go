@@ -0,0 +1,130 @@
+package mygroupcache
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// countingDialer fails while failing is set, and counts the dials
+type countingDialer struct {
+	dials   int
+	failing bool
+}
+
+func (d *countingDialer) dial(addr string) (*grpc.ClientConn, error) {
+	d.dials++
+	if d.failing {
+		return nil, fmt.Errorf("connection refused")
+	}
+	return grpc.NewClient("passthrough:///"+addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+}
+
+func TestConnManagerReuse(t *testing.T) {
+	d := &countingDialer{}
+	m := newConnManager(d.dial)
+	defer m.close()
+	if d.dials != 0 {
+		t.Fatal("connections should be dialed lazily")
+	}
+
+	c1, release, err := m.acquire("127.0.0.1:8001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	c2, release, _ := m.acquire("127.0.0.1:8001")
+	release()
+	if c1 != c2 || d.dials != 1 {
+		t.Fatalf("connection should be reused, dials = %d", d.dials)
+	}
+
+	// 被关闭的连接会重新拨号
+	c1.Close()
+	c3, _, err := m.acquire("127.0.0.1:8001")
+	if err != nil || c3 == c1 || d.dials != 2 {
+		t.Fatalf("closed connection should be redialed, dials = %d, err = %v", d.dials, err)
+	}
+}
+
+func TestConnManagerBackoff(t *testing.T) {
+	d := &countingDialer{failing: true}
+	m := newConnManager(d.dial)
+	defer m.close()
+
+	if _, _, err := m.acquire("127.0.0.1:8001"); !errors.Is(err, ErrPeerUnavailable) {
+		t.Fatalf("expected ErrPeerUnavailable, got %v", err)
+	}
+	// 退避期间不再拨号
+	if _, _, err := m.acquire("127.0.0.1:8001"); !errors.Is(err, ErrPeerUnavailable) || d.dials != 1 {
+		t.Fatalf("should not redial during backoff, dials = %d, err = %v", d.dials, err)
+	}
+
+	d.failing = false
+	time.Sleep(minDialBackoff + 10*time.Millisecond)
+	if _, _, err := m.acquire("127.0.0.1:8001"); err != nil || d.dials != 2 {
+		t.Fatalf("should redial after backoff, dials = %d, err = %v", d.dials, err)
+	}
+}
+
+func TestConnManagerRemove(t *testing.T) {
+	d := &countingDialer{}
+	m := newConnManager(d.dial)
+	conn, release, _ := m.acquire("127.0.0.1:8001")
+	release()
+	m.remove("127.0.0.1:8001")
+	if conn.GetState() != connectivity.Shutdown {
+		t.Fatal("remove should close the connection")
+	}
+
+	// 正在使用的连接在请求结束后才关闭
+	conn, release, _ = m.acquire("127.0.0.1:8003")
+	m.remove("127.0.0.1:8003")
+	if conn.GetState() == connectivity.Shutdown {
+		t.Fatal("remove should wait for the request in flight")
+	}
+	release()
+	if conn.GetState() != connectivity.Shutdown {
+		t.Fatal("connection should be closed once the last request is done")
+	}
+
+	conn, _, _ = m.acquire("127.0.0.1:8002")
+	m.close()
+	if conn.GetState() != connectivity.Shutdown {
+		t.Fatal("close should close every connection")
+	}
+	if _, _, err := m.acquire("127.0.0.1:8002"); !errors.Is(err, ErrPeerUnavailable) {
+		t.Fatalf("get after close should fail, got %v", err)
+	}
+}
+
+func TestDialBackoff(t *testing.T) {
+	if dialBackoff(1) != minDialBackoff || dialBackoff(2) != 2*minDialBackoff {
+		t.Fatalf("unexpected backoff %v %v", dialBackoff(1), dialBackoff(2))
+	}
+	if dialBackoff(100) != maxDialBackoff {
+		t.Fatalf("backoff should be capped, got %v", dialBackoff(100))
+	}
+}
+
+func TestGRPCPoolSharesConnections(t *testing.T) {
+	d := &countingDialer{}
+	s := NewGRPCPool("127.0.0.1:8001", 0, nil)
+	s.dial = d.dial
+	s.SetPeers("127.0.0.1:8001", "127.0.0.1:8002", "127.0.0.1:8003")
+	defer s.conns.close()
+
+	c1, release, _ := s.client["127.0.0.1:8002"].grpcClient()
+	release()
+	s.SetPeers("127.0.0.1:8001", "127.0.0.1:8002")
+	c2, release, _ := s.client["127.0.0.1:8002"].grpcClient()
+	release()
+	if c1 == nil || c2 == nil || d.dials != 1 {
+		t.Fatalf("SetPeers should keep the connections of remaining peers, dials = %d", d.dials)
+	}
+}
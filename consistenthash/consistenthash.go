@@ -3,7 +3,7 @@ package consistenthash
 
 import (
 	"hash/crc32"
-	"log"
+	"my_groupcache/logger"
 	"sort"
 	"strconv"
 	"sync"
@@ -19,6 +19,7 @@ type Map struct {
 	replicas int
 	keys     []int // Sorted
 	hashMap  map[int]string
+	logger   logger.Logger
 }
 
 // New creates a Map instance
@@ -27,6 +28,7 @@ func New(replicas int, fn Hash) *Map {
 		replicas: replicas,
 		hash:     fn,
 		hashMap:  make(map[int]string),
+		logger:   logger.Nop(),
 	}
 	if m.hash == nil {
 		m.hash = crc32.ChecksumIEEE
@@ -34,6 +36,11 @@ func New(replicas int, fn Hash) *Map {
 	return m
 }
 
+// SetLogger sets the logger of the map, nil discards the logs
+func (m *Map) SetLogger(l logger.Logger) {
+	m.logger = logger.OrNop(l)
+}
+
 // Add adds some keys to the hash.
 func (m *Map) Add(keys ...string) {
 	m.rwLock.Lock()
@@ -46,22 +53,21 @@ func (m *Map) Add(keys ...string) {
 		}
 	}
 	sort.Ints(m.keys)
+	m.logger.Debug("add nodes", "nodes", keys)
 }
 
 // Get gets the closest item in the hash to the provided key.
 func (m *Map) Get(key string) string {
+	m.rwLock.RLock()
+	defer m.rwLock.RUnlock()
 	if len(m.keys) == 0 {
 		return ""
 	}
-	m.rwLock.RLock()
-	defer m.rwLock.RUnlock()
 	hash := int(m.hash([]byte(key)))
 	// Binary search for appropriate replica.
 	idx := sort.Search(len(m.keys), func(i int) bool {
 		return m.keys[i] >= hash
 	})
-	s := m.hashMap[m.keys[idx%len(m.keys)]]
-	log.Printf("选择结点为: %s", s)
 	return m.hashMap[m.keys[idx%len(m.keys)]]
 }
 
@@ -77,4 +83,5 @@ func (m *Map) Remove(key string) {
 			delete(m.hashMap, hash)
 		}
 	}
-}
\ No newline at end of file
+	m.logger.Debug("remove node", "node", key)
+}
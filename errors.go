@@ -0,0 +1,94 @@
+package mygroupcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// ErrNotFound should be returned by a Getter when the key does not exist
+	ErrNotFound = errors.New("key not found")
+	// ErrGroupNotFound means the peer has no group with the requested name
+	ErrGroupNotFound = errors.New("no such group")
+	// ErrPeerUnavailable means the peer could not be reached
+	ErrPeerUnavailable = errors.New("peer unavailable")
+	// ErrLoaderFailed means the Getter failed to load the key
+	ErrLoaderFailed = errors.New("loader failed")
+	// ErrKeyRequired means an empty key was passed in
+	ErrKeyRequired = errors.New("key is required")
+	// ErrInvalidOption means NewGroupWithOptions got an invalid option
+	ErrInvalidOption = errors.New("invalid option")
+)
+
+// 错误与 grpc 状态码的对应关系，服务端编码，客户端解码
+var errorCodes = []struct {
+	err  error
+	code codes.Code
+}{
+	{ErrNotFound, codes.NotFound},
+	{ErrGroupNotFound, codes.FailedPrecondition},
+	{ErrKeyRequired, codes.InvalidArgument},
+	{ErrLoaderFailed, codes.Internal},
+	{context.Canceled, codes.Canceled},
+	{context.DeadlineExceeded, codes.DeadlineExceeded},
+}
+
+// toStatus converts err into a grpc status error for the wire
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	for _, ec := range errorCodes {
+		if errors.Is(err, ec.err) {
+			return status.Error(ec.code, err.Error())
+		}
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// fromStatus converts a grpc error returned by a peer back into one of the errors above,
+// anything we don't know is treated as ErrPeerUnavailable
+func fromStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return fmt.Errorf("%w: %v", ErrPeerUnavailable, err)
+	}
+	for _, ec := range errorCodes {
+		if st.Code() == ec.code {
+			return &peerError{err: ec.err, msg: st.Message()}
+		}
+	}
+	return &peerError{err: ErrPeerUnavailable, msg: st.Message()}
+}
+
+// peerError keeps the message sent by the peer, errors.Is matches the decoded error
+type peerError struct {
+	err error
+	msg string
+}
+
+func (e *peerError) Error() string {
+	return "peer: " + e.msg
+}
+
+func (e *peerError) Unwrap() error {
+	return e.err
+}
+
+// loaderError wraps an error returned by the Getter, ErrNotFound and ctx errors are kept as is
+func loaderError(err error) error {
+	if errors.Is(err, ErrNotFound) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrLoaderFailed, err)
+}
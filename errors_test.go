@@ -0,0 +1,56 @@
+package mygroupcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStatusRoundTrip(t *testing.T) {
+	cases := []struct {
+		err  error
+		code codes.Code
+		want error
+	}{
+		{fmt.Errorf("%w: Tom", ErrNotFound), codes.NotFound, ErrNotFound},
+		{fmt.Errorf("%w: scores", ErrGroupNotFound), codes.FailedPrecondition, ErrGroupNotFound},
+		{ErrKeyRequired, codes.InvalidArgument, ErrKeyRequired},
+		{loaderError(errors.New("db down")), codes.Internal, ErrLoaderFailed},
+		{errors.New("something else"), codes.Internal, ErrLoaderFailed},
+		{context.DeadlineExceeded, codes.DeadlineExceeded, context.DeadlineExceeded},
+	}
+	for _, c := range cases {
+		st := toStatus(c.err)
+		if status.Code(st) != c.code {
+			t.Errorf("toStatus(%v) code = %v, want %v", c.err, status.Code(st), c.code)
+		}
+		if back := fromStatus(st); !errors.Is(back, c.want) {
+			t.Errorf("fromStatus(%v) = %v, want errors.Is %v", st, back, c.want)
+		}
+	}
+}
+
+func TestFromStatusUnavailable(t *testing.T) {
+	err := fromStatus(status.Error(codes.Unavailable, "connection refused"))
+	if !errors.Is(err, ErrPeerUnavailable) {
+		t.Fatalf("expected ErrPeerUnavailable, got %v", err)
+	}
+	if err := fromStatus(errors.New("not a status")); !errors.Is(err, ErrPeerUnavailable) {
+		t.Fatalf("expected ErrPeerUnavailable, got %v", err)
+	}
+}
+
+func TestLoaderError(t *testing.T) {
+	dbErr := errors.New("db down")
+	err := loaderError(dbErr)
+	if !errors.Is(err, ErrLoaderFailed) || !errors.Is(err, dbErr) {
+		t.Fatalf("loader error should wrap both ErrLoaderFailed and the getter error, got %v", err)
+	}
+	if err := loaderError(ErrNotFound); err != ErrNotFound {
+		t.Fatalf("ErrNotFound should be kept as is, got %v", err)
+	}
+}
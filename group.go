@@ -1,11 +1,15 @@
 package mygroupcache
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"math/rand/v2"
 	pb "my_groupcache/cachepb"
+	"my_groupcache/logger"
 	"my_groupcache/singleflight"
 	"sync"
+	"time"
 )
 
 // 回调函数
@@ -20,6 +24,42 @@ func (f GetterFunc) Get(key string) (bytes []byte, err error) {
 	return f(key)
 }
 
+// ContextGetter 是可以感知 context 的回调函数，第一个调用方 ctx 中的值
+// 以及 WithMetadata 附带的值都会传递到这里。同一个 key 的加载由所有等待的
+// 调用方共享，deadline 取其中最晚的一个，只有全部调用方都放弃后 ctx 才会被取消
+type ContextGetter interface {
+	GetContext(ctx context.Context, key string) ([]byte, error)
+}
+
+// ContextGetterFunc 函数适配器，同时实现了 Getter 和 ContextGetter
+type ContextGetterFunc func(context.Context, string) ([]byte, error)
+
+func (f ContextGetterFunc) GetContext(ctx context.Context, key string) ([]byte, error) {
+	return f(ctx, key)
+}
+
+func (f ContextGetterFunc) Get(key string) ([]byte, error) {
+	return f(context.Background(), key)
+}
+
+// GetterWithTTL 回调函数可以为每个 key 指定过期时间，ttl 为 0 时使用 group 的
+// 默认过期时间，NoExpiration 表示不过期
+type GetterWithTTL interface {
+	GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error)
+}
+
+// GetterWithTTLFunc 函数适配器，同时实现了 Getter 和 GetterWithTTL
+type GetterWithTTLFunc func(context.Context, string) ([]byte, time.Duration, error)
+
+func (f GetterWithTTLFunc) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	return f(ctx, key)
+}
+
+func (f GetterWithTTLFunc) Get(key string) ([]byte, error) {
+	bytes, _, err := f(context.Background(), key)
+	return bytes, err
+}
+
 // TODO single-flight
 // A Group is a cache namespace and associated data loaded spread over
 type Group struct {
@@ -29,6 +69,26 @@ type Group struct {
 	peers PeerPicker
 	// singleflight
 	loader *singleflight.Group
+	// 缓存一部分从其他节点获取的数据，为空表示未开启
+	hotCache *cache
+	// 从其他节点获取的数据放入 hotCache 的比例
+	hotCacheSample float64
+	// 单次访问其他节点的超时时间，0 表示不限制
+	peerTimeout time.Duration
+	// 缓存不存在的 key，防止缓存穿透，为空表示未开启
+	negativeCache *cache
+	// 访问数据源前先查询布隆过滤器，为空表示未开启
+	bloom *bloomGuard
+	// 过期后继续返回旧值的时间，期间在后台刷新，0 表示未开启
+	staleGrace time.Duration
+	// 正在后台刷新的 key
+	refreshing sync.Map
+	// 提前刷新热点数据，为空表示未开启
+	refresher *refresher
+	// 统计信息
+	stats groupStats
+	// 日志带有 group 字段，默认不输出
+	logger logger.Logger
 }
 
 var (
@@ -41,16 +101,91 @@ func NewGroup(name string, maxBytes int, getter Getter) *Group {
 	if getter == nil {
 		panic("nil Getter")
 	}
+	g, err := NewGroupWithOptions(name, maxBytes, getter)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// NewGroupWithOptions is like NewGroup, the options are validated before
+// the group is registered
+func NewGroupWithOptions(name string, maxBytes int, getter Getter, opts ...GroupOption) (*Group, error) {
+	if getter == nil {
+		return nil, fmt.Errorf("%w: nil Getter", ErrInvalidOption)
+	}
+	if maxBytes < 0 {
+		return nil, fmt.Errorf("%w: negative maxBytes %d", ErrInvalidOption, maxBytes)
+	}
+	o := groupOptions{k: defaultK, hotCacheSample: defaultHotCacheSample}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	l := logger.With(o.logger, "group", name)
 	mu.Lock()
 	defer mu.Unlock()
 	g := &Group{
-		name:      name,
-		getter:    getter,
-		mainCache: cache{maxBytes: maxBytes},
-		loader: &singleflight.Group{},
+		name:   name,
+		getter: getter,
+		mainCache: cache{
+			maxBytes:         maxBytes,
+			k:                o.k,
+			policy:           o.policy,
+			shards:           o.shards,
+			readBuffer:       o.readBuffer,
+			ttl:              o.ttl,
+			jitter:           o.jitter,
+			evictionInterval: o.evictionInterval,
+			onEvicted:        o.onEvicted,
+			grace:            o.staleGrace,
+			logger:           logger.With(l, "cache", "main"),
+		},
+		loader:        &singleflight.Group{},
+		peerTimeout:   o.peerTimeout,
+		staleGrace:    o.staleGrace,
+		logger:        l,
+	}
+	if o.hotCacheBytes > 0 {
+		g.hotCache = &cache{
+			maxBytes:         o.hotCacheBytes,
+			k:                o.k,
+			policy:           o.policy,
+			shards:           o.shards,
+			readBuffer:       o.readBuffer,
+			ttl:              o.hotTTL(),
+			jitter:           o.jitter,
+			evictionInterval: o.evictionInterval,
+			logger:           logger.With(l, "cache", "hot"),
+		}
+		g.hotCacheSample = o.hotCacheSample
+	}
+	if o.negativeTTL > 0 {
+		g.negativeCache = &cache{
+			maxBytes:         o.negativeBytes,
+			k:                1,
+			ttl:              o.negativeTTL,
+			evictionInterval: o.evictionInterval,
+			logger:           logger.With(l, "cache", "negative"),
+		}
+	}
+	if o.refreshAhead != nil {
+		g.refresher = newRefresher(*o.refreshAhead)
+		g.mainCache.refreshAhead = o.refreshAhead.Fraction
+		g.mainCache.onRefresh = g.refresher.submit
+		g.runRefreshers()
+	}
+	if o.bloom != nil {
+		g.bloom = newBloomGuard(*o.bloom)
+		// 没有 key 枚举时从其他节点获取，等待 RegisterPeers
+		if o.bloom.Keys != nil {
+			go g.runBloom()
+		}
 	}
 	groups[name] = g
-	return g
+	return g, nil
 }
 
 // GetGroup returns the named group previously created with NewGroup, or
@@ -68,37 +203,79 @@ func (g *Group) RegisterPeers(peers PeerPicker) {
 		panic("RegisterPeerPicker called more than once")
 	}
 	g.peers = peers
+	if pool, ok := peers.(*GRPCPool); ok {
+		pool.addGroup(g)
+	}
+	if g.bloom != nil && g.bloom.opts.Keys == nil {
+		go g.runBloom()
+	}
 }
 
 // Get value for a key from cache
 func (g *Group) Get(key string) (ByteView, error) {
+	return g.GetContext(context.Background(), key)
+}
+
+// GetContext is like Get, ctx is passed through to the peer and the Getter
+func (g *Group) GetContext(ctx context.Context, key string) (ByteView, error) {
 	if key == "" {
-		return ByteView{}, fmt.Errorf("key is required")
+		return ByteView{}, ErrKeyRequired
 	}
+	g.stats.gets.Add(1)
 	// 本地调用
-	if v, ok := g.mainCache.get(key); ok {
-		log.Println("[GeeCache] hit")
+	if g.staleGrace > 0 {
+		if v, stale, ok := g.mainCache.getStale(key); ok {
+			g.stats.cacheHits.Add(1)
+			if stale {
+				g.stats.staleHits.Add(1)
+				g.refresh(key)
+			}
+			return v, nil
+		}
+	} else if v, ok := g.mainCache.get(key); ok {
+		g.stats.cacheHits.Add(1)
 		return v, nil
 	}
+	if g.hotCache != nil {
+		if v, ok := g.hotCache.get(key); ok {
+			g.stats.cacheHits.Add(1)
+			return v, nil
+		}
+	}
+	if g.negativeCache != nil {
+		if _, ok := g.negativeCache.get(key); ok {
+			g.stats.negativeHits.Add(1)
+			return ByteView{}, fmt.Errorf("%w: %s", ErrNotFound, key)
+		}
+	}
 
-	return g.load(key)
+	g.stats.loads.Add(1)
+	return g.load(ctx, key)
 }
 
 // 改造为调用远程结点 + 本地调用
-func (g *Group) load(key string) (value ByteView, err error) {
-	viewi, err := g.loader.Do(key, func() (interface{}, error) {
+func (g *Group) load(ctx context.Context, key string) (value ByteView, err error) {
+	viewi, err := g.loader.DoContext(ctx, key, func(ctx context.Context) (interface{}, error) {
+		g.stats.loadsDeduped.Add(1)
 		if g.peers != nil {
 			// pick peer
-			// log.Println(g.peers.PickPeer(key))
 			if peer, ok := g.peers.PickPeer(key); ok {
-				log.Printf("Client= %v\n", peer)
-				if value, err := g.getFromPeer(peer, key); err == nil {
-					log.Printf("Remote peers from %s\n", g.name)
+				value, err := g.getFromPeer(ctx, peer, key)
+				if err == nil {
+					g.stats.peerLoads.Add(1)
+					g.maybePopulateHotCache(key, value)
 					return value, nil
 				}
+				g.stats.peerErrors.Add(1)
+				// 只有 peer 不可达或超时才本地加载，owner 的 Getter 已经失败或者
+				// 确认不存在时，再加载一次只会给数据源增加压力
+				if !peerFailed(ctx, err) {
+					return nil, err
+				}
+				g.logger.Warn("get from peer failed, load locally", "key", key, "err", err)
 			}
 		}
-		return g.getLocally(key)
+		return g.getLocally(ctx, key)
 	})
 	
 	if err == nil {
@@ -107,7 +284,39 @@ func (g *Group) load(key string) (value ByteView, err error) {
 	return
 }
 
-func (g *Group) getFromPeer(peer PeerGetter, key string) (ByteView, error) {
+// peerFailed reports whether err means the peer could not answer, rather than
+// an answer from the owner, so that the key can be loaded locally instead
+func peerFailed(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	// ctx 还没结束，DeadlineExceeded 来自 peerTimeout
+	return errors.Is(err, ErrPeerUnavailable) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// refresh reloads key in the background, at most one refresh per key runs at a time
+func (g *Group) refresh(key string) {
+	if _, running := g.refreshing.LoadOrStore(key, struct{}{}); running {
+		return
+	}
+	g.stats.refreshes.Add(1)
+	go func() {
+		defer g.refreshing.Delete(key)
+		if _, err := g.load(context.Background(), key); err != nil {
+			g.logger.Warn("refresh failed", "key", key, "err", err)
+		}
+	}()
+}
+
+// maybePopulateHotCache keeps a sampled fraction of the values fetched from peers
+func (g *Group) maybePopulateHotCache(key string, value ByteView) {
+	if g.hotCache == nil || rand.Float64() >= g.hotCacheSample {
+		return
+	}
+	g.hotCache.add(key, value, 0)
+}
+
+func (g *Group) getFromPeer(ctx context.Context, peer PeerGetter, key string) (ByteView, error) {
 	// 调用客户端的Get
 	request := &pb.Request{
 		Group: g.name,
@@ -115,28 +324,173 @@ func (g *Group) getFromPeer(peer PeerGetter, key string) (ByteView, error) {
 	}
 	response := &pb.Response{}
 	if peer == nil {
-		log.Println("peer is nil")
+		return ByteView{}, ErrPeerUnavailable
 	}
-	err := peer.Get(request, response)
+	ctx, cancel := g.peerContext(ctx)
+	defer cancel()
+	err := peer.Get(ctx, request, response)
 	if err != nil {
-		log.Fatal(err.Error())
-		return ByteView{}, fmt.Errorf("Error: %s", err.Error())
+		return ByteView{}, err
 	}
 	return ByteView{b: response.Value}, nil
 }
 
-func (g *Group) getLocally(key string) (ByteView, error) {
-	bytes, err := g.getter.Get(key)
-	if err != nil {
+func (g *Group) getLocally(ctx context.Context, key string) (ByteView, error) {
+	// 调用方已经放弃了，不再访问数据源
+	if err := ctx.Err(); err != nil {
 		return ByteView{}, err
-
 	}
+	if g.bloom != nil && !g.bloom.mayContain(key) {
+		g.stats.bloomRejects.Add(1)
+		return ByteView{}, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	var bytes []byte
+	var ttl time.Duration
+	var err error
+	switch getter := g.getter.(type) {
+	case GetterWithTTL:
+		bytes, ttl, err = getter.GetWithTTL(ctx, key)
+	case ContextGetter:
+		bytes, err = getter.GetContext(ctx, key)
+	default:
+		bytes, err = getter.Get(key)
+	}
+	if err != nil {
+		g.stats.localLoadErrs.Add(1)
+		if g.negativeCache != nil && errors.Is(err, ErrNotFound) {
+			g.negativeCache.add(key, ByteView{}, 0)
+		}
+		return ByteView{}, loaderError(err)
+	}
+	g.stats.localLoads.Add(1)
 	value := ByteView{b: cloneBytes(bytes)}
-	g.populateCache(key, value)
-	log.Print("From db!")
+	g.populateCache(key, value, ttl)
 	return value, nil
 }
 
-func (g *Group) populateCache(key string, value ByteView) {
-	g.mainCache.add(key, value)
+func (g *Group) populateCache(key string, value ByteView, ttl time.Duration) {
+	g.mainCache.add(key, value, ttl)
+}
+
+// stop releases the background goroutines of the group
+func (g *Group) stop() {
+	g.mainCache.close()
+	if g.negativeCache != nil {
+		g.negativeCache.close()
+	}
+	if g.bloom != nil {
+		g.bloom.close()
+	}
+	if g.refresher != nil {
+		g.refresher.close()
+	}
+	if g.hotCache != nil {
+		g.hotCache.close()
+	}
+}
+
+// Set stores value on the peer that owns key, ttl <= 0 uses the default expire
+// time and NoExpiration stores a value that never expires
+func (g *Group) Set(key string, value []byte, ttl time.Duration) error {
+	if key == "" {
+		return ErrKeyRequired
+	}
+	if g.peers != nil {
+		if peer, ok := g.peers.PickPeer(key); ok {
+			g.removeHot(key)
+			return g.setToPeer(peer, key, value, ttl)
+		}
+	}
+	g.setLocally(key, ByteView{b: cloneBytes(value)}, ttl)
+	return nil
+}
+
+// Delete removes key from the peer that owns it
+func (g *Group) Delete(key string) error {
+	if key == "" {
+		return ErrKeyRequired
+	}
+	if g.peers != nil {
+		if peer, ok := g.peers.PickPeer(key); ok {
+			g.removeHot(key)
+			return g.deleteFromPeer(peer, key)
+		}
+	}
+	g.deleteLocally(key)
+	return nil
+}
+
+func (g *Group) setToPeer(peer PeerGetter, key string, value []byte, ttl time.Duration) error {
+	request := &pb.SetRequest{
+		Group: g.name,
+		Key:   key,
+		Value: value,
+		Ttl:   ttlMillis(ttl),
+	}
+	ctx, cancel := g.peerContext(context.Background())
+	defer cancel()
+	return peer.Set(ctx, request, &pb.Response{})
+}
+
+// ttlMillis converts ttl to the milliseconds sent to peers, rounding up so that
+// a ttl under a millisecond doesn't become the default of the owner.
+// NoExpiration is sent as -1
+func ttlMillis(ttl time.Duration) int64 {
+	if ttl == NoExpiration {
+		return -1
+	}
+	ms := ttl.Milliseconds()
+	if ttl > 0 && ttl%time.Millisecond != 0 {
+		ms++
+	}
+	return ms
+}
+
+func (g *Group) deleteFromPeer(peer PeerGetter, key string) error {
+	request := &pb.Request{
+		Group: g.name,
+		Key:   key,
+	}
+	ctx, cancel := g.peerContext(context.Background())
+	defer cancel()
+	return peer.Delete(ctx, request, &pb.ResponseForDelete{})
+}
+
+// peerContext applies the peer timeout of the group to ctx
+func (g *Group) peerContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if g.peerTimeout > 0 {
+		return context.WithTimeout(ctx, g.peerTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+func (g *Group) setLocally(key string, value ByteView, ttl time.Duration) {
+	if ttl < 0 && ttl != NoExpiration {
+		ttl = 0
+	}
+	if g.negativeCache != nil {
+		g.negativeCache.remove(key)
+	}
+	if g.bloom != nil {
+		g.bloom.add(key)
+	}
+	g.removeHot(key)
+	g.populateCache(key, value, ttl)
+}
+
+// deleteLocally reports whether key was cached
+func (g *Group) deleteLocally(key string) bool {
+	if g.negativeCache != nil {
+		g.negativeCache.remove(key)
+	}
+	hot := g.removeHot(key)
+	return g.mainCache.remove(key) || hot
+}
+
+// removeHot drops the copy of key fetched from its owner
+func (g *Group) removeHot(key string) bool {
+	if g.hotCache == nil {
+		return false
+	}
+	return g.hotCache.remove(key)
 }
\ No newline at end of file
@@ -0,0 +1,202 @@
+package mygroupcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"my_groupcache/bloom"
+	pb "my_groupcache/cachepb"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultFalsePositiveRate = 0.01
+	bloomRetryInterval       = time.Second
+	bloomPeerProbes          = 8
+)
+
+// BloomOptions configures the bloom filter consulted before calling the Getter
+type BloomOptions struct {
+	// Keys enumerates every key of the data source by calling add for each of them.
+	// If nil, the filter is fetched from a peer once peers are registered.
+	Keys func(ctx context.Context, add func(key string)) error
+	// ExpectedKeys sizes the filter, 0 means twice the number of enumerated keys
+	ExpectedKeys int
+	// FalsePositiveRate of the filter, 0.01 by default
+	FalsePositiveRate float64
+	// RebuildInterval rebuilds the filter periodically, 0 means it is built once
+	RebuildInterval time.Duration
+}
+
+func (o BloomOptions) validate() error {
+	switch {
+	case o.ExpectedKeys < 0:
+		return fmt.Errorf("%w: negative bloom filter size %d", ErrInvalidOption, o.ExpectedKeys)
+	case o.FalsePositiveRate < 0 || o.FalsePositiveRate >= 1:
+		return fmt.Errorf("%w: bloom false positive rate must be in [0, 1), got %v", ErrInvalidOption, o.FalsePositiveRate)
+	case o.RebuildInterval < 0:
+		return fmt.Errorf("%w: negative bloom rebuild interval %v", ErrInvalidOption, o.RebuildInterval)
+	}
+	return nil
+}
+
+// bloomGuard holds the current filter of a group, the filter is replaced as a
+// whole on rebuild
+type bloomGuard struct {
+	opts   BloomOptions
+	filter atomic.Pointer[bloom.Filter]
+	ctx    context.Context // 取消后停止重建
+	cancel context.CancelFunc
+
+	mu         sync.Mutex
+	rebuilding bool
+	pending    []string // 重建期间 Set 的 key，重建完成后加入新的 filter
+}
+
+func newBloomGuard(opts BloomOptions) *bloomGuard {
+	if opts.FalsePositiveRate == 0 {
+		opts.FalsePositiveRate = defaultFalsePositiveRate
+	}
+	b := &bloomGuard{opts: opts}
+	b.ctx, b.cancel = context.WithCancel(context.Background())
+	return b
+}
+
+// mayContain reports false only if key is definitely not in the data source,
+// everything may exist before the first build
+func (b *bloomGuard) mayContain(key string) bool {
+	f := b.filter.Load()
+	return f == nil || f.Test(key)
+}
+
+func (b *bloomGuard) add(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if f := b.filter.Load(); f != nil {
+		f.Add(key)
+	}
+	if b.rebuilding {
+		b.pending = append(b.pending, key)
+	}
+}
+
+// replace swaps in f with the keys Set during the rebuild
+func (b *bloomGuard) replace(f *bloom.Filter) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, key := range b.pending {
+		f.Add(key)
+	}
+	b.pending = nil
+	b.filter.Store(f)
+}
+
+func (b *bloomGuard) setRebuilding(rebuilding bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rebuilding = rebuilding
+	if !rebuilding {
+		b.pending = nil
+	}
+}
+
+func (b *bloomGuard) close() {
+	b.cancel()
+}
+
+// runBloom builds the filter, retrying until the first build succeeds, then
+// rebuilds it every RebuildInterval until the group is stopped
+func (g *Group) runBloom() {
+	b := g.bloom
+	for {
+		wait := b.opts.RebuildInterval
+		if err := g.rebuildBloom(b.ctx); err != nil {
+			if b.ctx.Err() != nil {
+				return
+			}
+			g.logger.Warn("build bloom filter failed", "err", err)
+			if b.filter.Load() == nil {
+				wait = bloomRetryInterval
+			}
+		}
+		if wait <= 0 {
+			return
+		}
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// rebuildBloom builds a new filter from the key enumerator, or fetches it from a peer
+func (g *Group) rebuildBloom(ctx context.Context) error {
+	b := g.bloom
+	b.setRebuilding(true)
+	defer b.setRebuilding(false)
+
+	if b.opts.Keys == nil {
+		f, err := g.fetchBloomFromPeer(ctx)
+		if err != nil {
+			return err
+		}
+		b.replace(f)
+		return nil
+	}
+
+	var keys []string
+	if err := b.opts.Keys(ctx, func(key string) {
+		keys = append(keys, key)
+	}); err != nil {
+		return err
+	}
+	n := b.opts.ExpectedKeys
+	if n == 0 {
+		n = 2 * len(keys)
+	}
+	f := bloom.New(n, b.opts.FalsePositiveRate)
+	for _, key := range keys {
+		f.Add(key)
+	}
+	b.replace(f)
+	return nil
+}
+
+func (g *Group) fetchBloomFromPeer(ctx context.Context) (*bloom.Filter, error) {
+	if g.peers == nil {
+		return nil, errors.New("no peers registered")
+	}
+	// 本节点可能就是 g.name 的 owner，换几个 key 找一个其他节点
+	var peer PeerGetter
+	for i := 0; i < bloomPeerProbes && peer == nil; i++ {
+		if p, ok := g.peers.PickPeer(g.name + "/" + strconv.Itoa(i)); ok {
+			peer = p
+		}
+	}
+	if peer == nil {
+		return nil, errors.New("no peer to fetch the bloom filter from")
+	}
+	fetcher, ok := peer.(BloomFetcher)
+	if !ok {
+		return nil, fmt.Errorf("peer %v cannot ship bloom filters", peer)
+	}
+	ctx, cancel := g.peerContext(ctx)
+	defer cancel()
+	out := &pb.BloomFilter{}
+	if err := fetcher.GetBloomFilter(ctx, &pb.BloomRequest{Group: g.name}, out); err != nil {
+		return nil, err
+	}
+	return bloom.FromBits(out.GetBits(), out.GetK())
+}
+
+// bloomFilter returns the current filter of the group, nil if it has none yet
+func (g *Group) bloomFilter() *bloom.Filter {
+	if g.bloom == nil {
+		return nil
+	}
+	return g.bloom.filter.Load()
+}
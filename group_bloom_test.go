@@ -0,0 +1,136 @@
+package mygroupcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"my_groupcache/bloom"
+	pb "my_groupcache/cachepb"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitBloom waits until the group has built its bloom filter
+func waitBloom(t *testing.T, g *Group) *bloom.Filter {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if f := g.bloomFilter(); f != nil {
+			return f
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("bloom filter was not built")
+	return nil
+}
+
+func TestGroupBloomFilter(t *testing.T) {
+	var mu sync.Mutex
+	keys := []string{"Tom", "Jack", "Sam"}
+	loads := 0
+	g, err := NewGroupWithOptions("bloom", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		mu.Lock()
+		loads++
+		mu.Unlock()
+		return []byte("v-" + key), nil
+	}), WithBloomFilter(BloomOptions{
+		Keys: func(ctx context.Context, add func(string)) error {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, key := range keys {
+				add(key)
+			}
+			return nil
+		},
+		RebuildInterval: 50 * time.Millisecond,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.stop()
+	waitBloom(t, g)
+	loadCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return loads
+	}
+
+	if _, err := g.Get("Kaido"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if v, err := g.Get("Tom"); err != nil || v.String() != "v-Tom" {
+		t.Fatalf("expected v-Tom, got %v (err=%v)", v, err)
+	}
+	if loadCount() != 1 {
+		t.Fatalf("filtered key should not reach the getter, loads = %d", loadCount())
+	}
+
+	// Set 的 key 加入过滤器
+	g.Set("Kaido", []byte("v"), 0)
+	g.deleteLocally("Kaido")
+	if _, err := g.Get("Kaido"); err != nil || loadCount() != 2 {
+		t.Fatalf("key added by Set should reach the getter, err = %v, loads = %d", err, loadCount())
+	}
+
+	// 定期重建时加入数据源新增的 key
+	mu.Lock()
+	keys = append(keys, "Luffy")
+	mu.Unlock()
+	deadline := time.Now().Add(3 * time.Second)
+	for !g.bloom.mayContain("Luffy") {
+		if time.Now().After(deadline) {
+			t.Fatal("rebuild should pick up new keys")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGroupBloomOptions(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) { return nil, nil })
+	invalid := []BloomOptions{
+		{ExpectedKeys: -1},
+		{FalsePositiveRate: 1},
+		{RebuildInterval: -time.Second},
+	}
+	for i, opts := range invalid {
+		if _, err := NewGroupWithOptions("bloom-invalid", 2<<10, getter, WithBloomFilter(opts)); !errors.Is(err, ErrInvalidOption) {
+			t.Fatalf("case %d: expected ErrInvalidOption, got %v", i, err)
+		}
+	}
+}
+
+// bloomPeer ships filter to whoever asks
+type bloomPeer struct {
+	fakePeer
+	filter *bloom.Filter
+}
+
+func (b *bloomPeer) GetBloomFilter(ctx context.Context, in *pb.BloomRequest, out *pb.BloomFilter) error {
+	if b.filter == nil {
+		return fmt.Errorf("%w: no filter", ErrPeerUnavailable)
+	}
+	out.Bits, out.K = b.filter.Bits(), b.filter.K()
+	return nil
+}
+
+func TestGroupBloomFromPeer(t *testing.T) {
+	f := bloom.New(100, 0.01)
+	f.Add("Tom")
+	g, err := NewGroupWithOptions("bloom-peer", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("v"), nil
+	}), WithBloomFilter(BloomOptions{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.stop()
+	g.RegisterPeers(fakePicker{peer: &bloomPeer{filter: f}})
+
+	got := waitBloom(t, g)
+	if !got.Test("Tom") || got.K() != f.K() {
+		t.Fatal("filter fetched from the peer should contain Tom")
+	}
+	if g.bloom.mayContain("Kaido") {
+		t.Fatal("Kaido should be filtered")
+	}
+}
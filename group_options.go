@@ -0,0 +1,240 @@
+package mygroupcache
+
+import (
+	"fmt"
+	"my_groupcache/logger"
+	"my_groupcache/lru"
+	"time"
+)
+
+// NoExpiration can be passed to WithDefaultTTL so that entries never expire
+const NoExpiration time.Duration = -1
+
+const (
+	defaultK              = 2
+	defaultHotCacheSample = 0.1
+	// 没有设置 WithHotCacheTTL 时热点缓存过期时间的上限，
+	// 其他节点上的 Set 和 Delete 最多这么久之后可见
+	maxDefaultHotCacheTTL = time.Minute
+)
+
+// GroupOption configures a Group created by NewGroupWithOptions
+type GroupOption func(*groupOptions)
+
+type groupOptions struct {
+	k                int
+	policy           lru.PolicyType
+	shards           int
+	readBuffer       int
+	ttl              time.Duration
+	jitter           lru.Jitter
+	evictionInterval time.Duration
+	onEvicted        func(key string, value ByteView)
+	hotCacheBytes    int
+	hotCacheTTL      time.Duration
+	hotCacheSample   float64
+	peerTimeout      time.Duration
+	negativeTTL      time.Duration
+	negativeBytes    int
+	bloom            *BloomOptions
+	staleGrace       time.Duration
+	refreshAhead     *RefreshAheadOptions
+	logger           logger.Logger
+}
+
+// WithK sets how many times a key has to be accessed before it is moved
+// from the history of the LRU-K cache to the hot tier, 2 by default
+func WithK(k int) GroupOption {
+	return func(o *groupOptions) {
+		o.k = k
+	}
+}
+
+// WithEvictionPolicy sets the eviction policy of the main cache and of the
+// hot cache, lru.PolicyLRUK by default
+func WithEvictionPolicy(policy lru.PolicyType) GroupOption {
+	return func(o *groupOptions) {
+		o.policy = policy
+	}
+}
+
+// WithShards splits the main cache and the hot cache into n shards, each with
+// its own lock and 1/n of the bytes, so that concurrent gets don't wait for
+// each other. n is rounded up to a power of two and lowered so that every shard
+// gets at least one byte, 0 by default picks one shard per CPU while every
+// shard keeps at least 64KB. An entry larger than the bytes of its shard is
+// not cached.
+func WithShards(n int) GroupOption {
+	return func(o *groupOptions) {
+		o.shards = n
+	}
+}
+
+// WithReadBuffer makes hits of the main cache and of the hot cache take only a
+// read lock: hit keys are recorded in a lossy ring buffer of size slots per
+// shard and applied to the eviction policy in batches. Some hits may be lost
+// under contention and expired entries are removed by the cleanup instead of
+// by the get that finds them. 0 by default updates the policy on every hit.
+func WithReadBuffer(size int) GroupOption {
+	return func(o *groupOptions) {
+		o.readBuffer = size
+	}
+}
+
+// WithDefaultTTL sets the expire time of loaded entries, 2s by default
+func WithDefaultTTL(ttl time.Duration) GroupOption {
+	return func(o *groupOptions) {
+		o.ttl = ttl
+	}
+}
+
+// WithTTLJitter spreads the expire time of entries loaded together, no jitter by default
+func WithTTLJitter(jitter lru.Jitter) GroupOption {
+	return func(o *groupOptions) {
+		o.jitter = jitter
+	}
+}
+
+// WithEvictionInterval sets how often expired entries are cleaned up, 60s by default
+func WithEvictionInterval(interval time.Duration) GroupOption {
+	return func(o *groupOptions) {
+		o.evictionInterval = interval
+	}
+}
+
+// WithOnEvicted sets a callback run when an entry is evicted, expires or is deleted.
+// It runs with the cache locked and must not call back into the group.
+func WithOnEvicted(fn func(key string, value ByteView)) GroupOption {
+	return func(o *groupOptions) {
+		o.onEvicted = fn
+	}
+}
+
+// WithHotCacheBytes sets the byte budget for values fetched from peers, 0 disables it
+func WithHotCacheBytes(n int) GroupOption {
+	return func(o *groupOptions) {
+		o.hotCacheBytes = n
+	}
+}
+
+// WithHotCacheTTL sets the expire time of values fetched from peers. By default
+// it is the default expire time of the group, but at most one minute even with
+// NoExpiration. Keep it short, other nodes only see Set and Delete once their
+// copy expires.
+func WithHotCacheTTL(ttl time.Duration) GroupOption {
+	return func(o *groupOptions) {
+		o.hotCacheTTL = ttl
+	}
+}
+
+// WithHotCacheSampleRate sets the fraction of values fetched from peers that
+// are kept in the hot cache, 0.1 by default
+func WithHotCacheSampleRate(rate float64) GroupOption {
+	return func(o *groupOptions) {
+		o.hotCacheSample = rate
+	}
+}
+
+// WithPeerTimeout bounds each call to a peer, 0 keeps the caller's deadline
+// or the client default of 5s
+func WithPeerTimeout(timeout time.Duration) GroupOption {
+	return func(o *groupOptions) {
+		o.peerTimeout = timeout
+	}
+}
+
+// WithNegativeCache caches ErrNotFound returned by the Getter for ttl, using at
+// most maxBytes, so that requests for missing keys don't all reach the data source.
+// Disabled by default.
+func WithNegativeCache(ttl time.Duration, maxBytes int) GroupOption {
+	return func(o *groupOptions) {
+		o.negativeTTL = ttl
+		o.negativeBytes = maxBytes
+	}
+}
+
+// WithLogger sets the logger of the group and its caches, messages carry the
+// group name. Nothing is logged by default.
+func WithLogger(l logger.Logger) GroupOption {
+	return func(o *groupOptions) {
+		o.logger = l
+	}
+}
+
+// WithBloomFilter consults a bloom filter of the keys of the data source before
+// calling the Getter, keys that are definitely missing return ErrNotFound
+func WithBloomFilter(opts BloomOptions) GroupOption {
+	return func(o *groupOptions) {
+		o.bloom = &opts
+	}
+}
+
+// WithStaleWhileRevalidate keeps serving an expired entry for grace while a
+// single background load refreshes it
+func WithStaleWhileRevalidate(grace time.Duration) GroupOption {
+	return func(o *groupOptions) {
+		o.staleGrace = grace
+	}
+}
+
+// WithRefreshAhead reloads entries of the hot tier in the background when they
+// are accessed close to their expiry, so that hot keys don't miss
+func WithRefreshAhead(opts RefreshAheadOptions) GroupOption {
+	return func(o *groupOptions) {
+		o.refreshAhead = &opts
+	}
+}
+
+// hotTTL is the expire time of the hot cache, never NoExpiration
+func (o *groupOptions) hotTTL() time.Duration {
+	switch {
+	case o.hotCacheTTL > 0:
+		return o.hotCacheTTL
+	case o.ttl == NoExpiration || o.ttl > maxDefaultHotCacheTTL:
+		return maxDefaultHotCacheTTL
+	}
+	// 0 使用 lru 的默认过期时间
+	return o.ttl
+}
+
+func (o *groupOptions) validate() error {
+	switch {
+	case o.k < 1:
+		return fmt.Errorf("%w: k must be at least 1, got %d", ErrInvalidOption, o.k)
+	case !o.policy.Valid():
+		return fmt.Errorf("%w: unknown eviction policy %d", ErrInvalidOption, int(o.policy))
+	case o.shards < 0:
+		return fmt.Errorf("%w: negative shard count %d", ErrInvalidOption, o.shards)
+	case o.readBuffer < 0:
+		return fmt.Errorf("%w: negative read buffer size %d", ErrInvalidOption, o.readBuffer)
+	case o.ttl < 0 && o.ttl != NoExpiration:
+		return fmt.Errorf("%w: negative ttl %v", ErrInvalidOption, o.ttl)
+	case o.jitter.Validate() != nil:
+		return fmt.Errorf("%w: %v", ErrInvalidOption, o.jitter.Validate())
+	case o.evictionInterval < 0:
+		return fmt.Errorf("%w: negative eviction interval %v", ErrInvalidOption, o.evictionInterval)
+	case o.hotCacheBytes < 0:
+		return fmt.Errorf("%w: negative hot cache size %d", ErrInvalidOption, o.hotCacheBytes)
+	case o.hotCacheTTL < 0:
+		return fmt.Errorf("%w: negative hot cache ttl %v", ErrInvalidOption, o.hotCacheTTL)
+	case o.hotCacheSample < 0 || o.hotCacheSample > 1:
+		return fmt.Errorf("%w: hot cache sample rate must be in [0, 1], got %v", ErrInvalidOption, o.hotCacheSample)
+	case o.peerTimeout < 0:
+		return fmt.Errorf("%w: negative peer timeout %v", ErrInvalidOption, o.peerTimeout)
+	case o.staleGrace < 0:
+		return fmt.Errorf("%w: negative stale grace %v", ErrInvalidOption, o.staleGrace)
+	case o.negativeTTL < 0 || o.negativeBytes < 0:
+		return fmt.Errorf("%w: negative cache ttl %v and size %d must not be negative", ErrInvalidOption, o.negativeTTL, o.negativeBytes)
+	case (o.negativeTTL > 0) != (o.negativeBytes > 0):
+		return fmt.Errorf("%w: negative cache needs both a ttl and a size", ErrInvalidOption)
+	}
+	if o.bloom != nil {
+		if err := o.bloom.validate(); err != nil {
+			return err
+		}
+	}
+	if o.refreshAhead != nil {
+		return o.refreshAhead.validate()
+	}
+	return nil
+}
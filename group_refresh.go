@@ -0,0 +1,142 @@
+package mygroupcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRefreshWorkers = 4
+	refreshQueuePerWorker = 16
+)
+
+// RefreshAheadOptions configures refresh-ahead of the hot tier of the cache
+type RefreshAheadOptions struct {
+	// Fraction of the ttl: an entry of the hot tier accessed with less than
+	// Fraction*ttl left is reloaded with the Getter in the background, in (0, 1)
+	Fraction float64
+	// Workers reloading entries, 4 by default
+	Workers int
+	// Rate limits reloads per second, 0 means unlimited
+	Rate float64
+}
+
+func (o RefreshAheadOptions) validate() error {
+	switch {
+	case o.Fraction <= 0 || o.Fraction >= 1:
+		return fmt.Errorf("%w: refresh-ahead fraction must be in (0, 1), got %v", ErrInvalidOption, o.Fraction)
+	case o.Workers < 0:
+		return fmt.Errorf("%w: negative refresh-ahead workers %d", ErrInvalidOption, o.Workers)
+	case o.Rate < 0:
+		return fmt.Errorf("%w: negative refresh-ahead rate %v", ErrInvalidOption, o.Rate)
+	}
+	return nil
+}
+
+// refresher reloads keys submitted by the cache with a fixed number of workers
+type refresher struct {
+	workers int
+	queue   chan string
+	limiter *tokenBucket // 为空表示不限速
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+func newRefresher(opts RefreshAheadOptions) *refresher {
+	if opts.Workers == 0 {
+		opts.Workers = defaultRefreshWorkers
+	}
+	r := &refresher{workers: opts.Workers, queue: make(chan string, opts.Workers*refreshQueuePerWorker)}
+	if opts.Rate > 0 {
+		r.limiter = newTokenBucket(opts.Rate)
+	}
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	return r
+}
+
+// submit queues key without blocking, it is called with the cache locked
+func (r *refresher) submit(key string) bool {
+	if r.ctx.Err() != nil || (r.limiter != nil && !r.limiter.allow()) {
+		return false
+	}
+	select {
+	case r.queue <- key:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *refresher) close() {
+	r.cancel()
+}
+
+// runRefreshers starts the workers of the refresher of the group
+func (g *Group) runRefreshers() {
+	r := g.refresher
+	for i := 0; i < r.workers; i++ {
+		go func() {
+			for {
+				select {
+				case <-r.ctx.Done():
+					return
+				case key := <-r.queue:
+					g.refreshAhead(r.ctx, key)
+				}
+			}
+		}()
+	}
+}
+
+// refreshAhead reloads key with the Getter, sharing the load with concurrent misses
+func (g *Group) refreshAhead(ctx context.Context, key string) {
+	g.stats.refreshes.Add(1)
+	_, err := g.loader.DoContext(ctx, key, func(ctx context.Context) (interface{}, error) {
+		return g.getLocally(ctx, key)
+	})
+	if err == nil || ctx.Err() != nil {
+		return
+	}
+	// 数据源中已经不存在，不再返回旧值
+	if errors.Is(err, ErrNotFound) {
+		g.mainCache.remove(key)
+		return
+	}
+	g.logger.Warn("refresh ahead failed", "key", key, "err", err)
+}
+
+// tokenBucket allows rate events per second, with bursts of up to rate events
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64) *tokenBucket {
+	burst := rate
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+func (b *tokenBucket) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,114 @@
+package mygroupcache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGroupRefreshAhead(t *testing.T) {
+	var mu sync.Mutex
+	loads := make(map[string]int)
+	g, err := NewGroupWithOptions("refresh-ahead", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		loads[key]++
+		return []byte(fmt.Sprintf("%s-%d", key, loads[key])), nil
+	}), WithDefaultTTL(300*time.Millisecond), WithRefreshAhead(RefreshAheadOptions{Fraction: 0.5, Workers: 2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.stop()
+	loadsOf := func(key string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return loads[key]
+	}
+
+	g.Get("hot")
+	g.Get("hot") // 进入热点层
+	g.Get("cold")
+	time.Sleep(200 * time.Millisecond)
+	g.Get("hot")
+	g.Get("cold")
+
+	deadline := time.Now().Add(3 * time.Second)
+	for loadsOf("hot") < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("hot key should be refreshed ahead of its expiry")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if v, _ := g.mainCache.get("hot"); v.String() != "hot-2" {
+		t.Fatalf("expected the refreshed value hot-2, got %s", v)
+	}
+	if loadsOf("cold") != 1 {
+		t.Fatalf("keys outside the hot tier should not be refreshed, loads = %d", loadsOf("cold"))
+	}
+}
+
+func TestGroupRefreshAheadRateLimit(t *testing.T) {
+	var mu sync.Mutex
+	loads := 0
+	g, err := NewGroupWithOptions("refresh-ahead-rate", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		loads++
+		return []byte("v"), nil
+	}), WithDefaultTTL(time.Minute), WithRefreshAhead(RefreshAheadOptions{Fraction: 0.99, Rate: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.stop()
+
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		g.Get(key)
+		g.Get(key)
+	}
+	time.Sleep(time.Second / 2)
+	for i := 0; i < 10; i++ {
+		g.Get(fmt.Sprintf("key-%d", i))
+	}
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	// 10 次初始加载，限速后最多刷新一次
+	if loads > 11 {
+		t.Fatalf("refresh-ahead should be rate limited, loads = %d", loads)
+	}
+}
+
+func TestRefreshAheadOptions(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) { return nil, nil })
+	invalid := []RefreshAheadOptions{
+		{Fraction: 0},
+		{Fraction: 1},
+		{Fraction: 0.5, Workers: -1},
+		{Fraction: 0.5, Rate: -1},
+	}
+	for i, opts := range invalid {
+		if _, err := NewGroupWithOptions("refresh-ahead-invalid", 2<<10, getter, WithRefreshAhead(opts)); !errors.Is(err, ErrInvalidOption) {
+			t.Fatalf("case %d: expected ErrInvalidOption, got %v", i, err)
+		}
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(10)
+	n := 0
+	for i := 0; i < 100; i++ {
+		if b.allow() {
+			n++
+		}
+	}
+	if n != 10 {
+		t.Fatalf("expected a burst of 10, got %d", n)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if !b.allow() {
+		t.Fatal("tokens should be refilled over time")
+	}
+}
@@ -2,11 +2,22 @@
 package mygroupcache
 
 import (
+	"context"
+	"errors"
+	"bytes"
 	"fmt"
 	"log"
+	"log/slog"
+	pb "my_groupcache/cachepb"
+	"my_groupcache/logger"
+	"my_groupcache/lru"
+	"strings"
 	"sync"
 	"testing"
 	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var db = map[string]string{
@@ -68,3 +79,733 @@ func TestGroupServer(t *testing.T) {
 	g.Get("Tom")
 	
 }
+
+// fakePeer records what was routed to it
+type fakePeer struct {
+	mu      sync.Mutex
+	sets    map[string][]byte
+	ttls    map[string]int64
+	deletes []string
+	lastCtx context.Context
+}
+
+func (f *fakePeer) Get(ctx context.Context, in *pb.Request, out *pb.Response) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastCtx = ctx
+	v, ok := f.sets[in.GetKey()]
+	if !ok {
+		// 没有设置的 key 当作 peer 不可达，调用方会本地加载
+		return fmt.Errorf("%w: %s not exist", ErrPeerUnavailable, in.GetKey())
+	}
+	out.Value = v
+	return nil
+}
+
+func (f *fakePeer) Set(ctx context.Context, in *pb.SetRequest, out *pb.Response) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.sets == nil {
+		f.sets = make(map[string][]byte)
+	}
+	if f.ttls == nil {
+		f.ttls = make(map[string]int64)
+	}
+	f.sets[in.GetKey()] = in.GetValue()
+	f.ttls[in.GetKey()] = in.GetTtl()
+	return nil
+}
+
+func (f *fakePeer) Delete(ctx context.Context, in *pb.Request, out *pb.ResponseForDelete) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, out.Value = f.sets[in.GetKey()]
+	delete(f.sets, in.GetKey())
+	f.deletes = append(f.deletes, in.GetKey())
+	return nil
+}
+
+// fakePicker sends every key to peer
+type fakePicker struct {
+	peer PeerGetter
+}
+
+func (p fakePicker) PickPeer(key string) (PeerGetter, bool) {
+	return p.peer, p.peer != nil
+}
+
+func TestGroupSetDeleteLocally(t *testing.T) {
+	loads := 0
+	g := NewGroup("set-local", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		if v, ok := db[key]; ok {
+			return []byte(v), nil
+		}
+		return nil, fmt.Errorf("%s not exist", key)
+	}))
+
+	if err := g.Set("Luffy", []byte("999"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := g.Get("Luffy"); err != nil || v.String() != "999" {
+		t.Fatalf("expected 999, got %v (err=%v)", v, err)
+	}
+	if v, err := g.Get("Luffy"); err != nil || v.String() != "999" {
+		t.Fatalf("expected 999 after promotion, got %v (err=%v)", v, err)
+	}
+	if loads != 0 {
+		t.Fatalf("Set value should be served without the getter, loads = %d", loads)
+	}
+
+	if err := g.Delete("Luffy"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Get("Luffy"); err == nil {
+		t.Fatal("expected Luffy to be deleted")
+	}
+	if loads != 1 {
+		t.Fatalf("expected getter to be called after Delete, loads = %d", loads)
+	}
+}
+
+func TestGroupSetExpire(t *testing.T) {
+	g := NewGroup("set-expire", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return nil, fmt.Errorf("%s not exist", key)
+	}))
+	if err := g.Set("Zoro", []byte("1"), 100*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Get("Zoro"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if _, err := g.Get("Zoro"); err == nil {
+		t.Fatal("expected Zoro to expire")
+	}
+}
+
+func TestGroupSetNoExpiration(t *testing.T) {
+	g, err := NewGroupWithOptions("set-no-expiration", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return nil, fmt.Errorf("%s not exist", key)
+	}), WithDefaultTTL(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Set("Usopp", []byte("1"), NoExpiration); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, err := g.Get("Usopp"); err != nil {
+		t.Fatalf("Set with NoExpiration should not use the default ttl: %v", err)
+	}
+
+	peer := &fakePeer{}
+	g.RegisterPeers(fakePicker{peer: peer})
+	if err := g.Set("Usopp", []byte("1"), NoExpiration); err != nil {
+		t.Fatal(err)
+	}
+	if peer.ttls["Usopp"] != -1 {
+		t.Fatalf("NoExpiration should be sent as -1, got %d", peer.ttls["Usopp"])
+	}
+}
+
+func TestGroupSetDeleteToPeer(t *testing.T) {
+	g := NewGroup("set-peer", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return nil, fmt.Errorf("%s not exist", key)
+	}))
+	peer := &fakePeer{}
+	g.RegisterPeers(fakePicker{peer: peer})
+
+	if err := g.Set("Nami", []byte("679"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if string(peer.sets["Nami"]) != "679" {
+		t.Fatalf("Set should be routed to the owner, got %v", peer.sets)
+	}
+	if _, ok := g.mainCache.get("Nami"); ok {
+		t.Fatal("Set routed to a peer should not populate the local cache")
+	}
+	// 不足 1 毫秒的 ttl 向上取整，不能变成 owner 的默认值
+	if err := g.Set("Nami", []byte("679"), 500*time.Microsecond); err != nil {
+		t.Fatal(err)
+	}
+	if peer.ttls["Nami"] != 1 {
+		t.Fatalf("Set should send a ttl of 1ms, got %dms", peer.ttls["Nami"])
+	}
+
+	if err := g.Delete("Nami"); err != nil {
+		t.Fatal(err)
+	}
+	if len(peer.deletes) != 1 || peer.deletes[0] != "Nami" {
+		t.Fatalf("Delete should be routed to the owner, got %v", peer.deletes)
+	}
+}
+
+func TestTTLMillis(t *testing.T) {
+	tests := []struct {
+		ttl  time.Duration
+		want int64
+	}{
+		{0, 0},
+		{time.Nanosecond, 1},
+		{500 * time.Microsecond, 1},
+		{time.Millisecond, 1},
+		{1500 * time.Microsecond, 2},
+		{time.Minute, 60000},
+		{NoExpiration, -1},
+	}
+	for _, tt := range tests {
+		if got := ttlMillis(tt.ttl); got != tt.want {
+			t.Errorf("ttlMillis(%v) = %d, want %d", tt.ttl, got, tt.want)
+		}
+	}
+}
+
+func TestGroupGetContext(t *testing.T) {
+	type tenantKey struct{}
+	var gotTenant interface{}
+	var gotTrace []string
+	loads := 0
+	g := NewGroup("get-context", 2<<10, ContextGetterFunc(func(ctx context.Context, key string) ([]byte, error) {
+		loads++
+		gotTenant = ctx.Value(tenantKey{})
+		gotTrace = MetadataFromContext(ctx).Get("trace-id")
+		if v, ok := db[key]; ok {
+			return []byte(v), nil
+		}
+		return nil, fmt.Errorf("%s not exist", key)
+	}))
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "t1")
+	ctx = WithMetadata(ctx, "trace-id", "abc")
+	if v, err := g.GetContext(ctx, "Tom"); err != nil || v.String() != "630" {
+		t.Fatalf("expected 630, got %v (err=%v)", v, err)
+	}
+	if gotTenant != "t1" || len(gotTrace) != 1 || gotTrace[0] != "abc" {
+		t.Fatalf("ctx values should reach the getter, got tenant=%v trace=%v", gotTenant, gotTrace)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := g.GetContext(canceled, "Jack"); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if loads != 1 {
+		t.Fatalf("getter should not be called for a canceled ctx, loads = %d", loads)
+	}
+}
+
+func TestGroupGetContextToPeer(t *testing.T) {
+	g := NewGroup("get-context-peer", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return nil, fmt.Errorf("%s not exist", key)
+	}))
+	peer := &fakePeer{sets: map[string][]byte{"Sam": []byte("567")}}
+	g.RegisterPeers(fakePicker{peer: peer})
+
+	type tenantKey struct{}
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), tenantKey{}, "t1"), time.Minute)
+	defer cancel()
+	g.GetContext(ctx, "Sam")
+	if peer.lastCtx == nil {
+		t.Fatal("peer was not called")
+	}
+	want, _ := ctx.Deadline()
+	if d, ok := peer.lastCtx.Deadline(); !ok || !d.Equal(want) {
+		t.Fatal("caller deadline should reach the peer")
+	}
+	if peer.lastCtx.Value(tenantKey{}) != "t1" {
+		t.Fatal("ctx values should reach the peer")
+	}
+}
+
+// brokenPeer always fails with err
+type brokenPeer struct {
+	fakePeer
+	err   error
+	calls int
+}
+
+func (b *brokenPeer) Get(ctx context.Context, in *pb.Request, out *pb.Response) error {
+	b.calls++
+	return b.err
+}
+
+func TestGroupPeerErrorFallback(t *testing.T) {
+	loads := 0
+	g := NewGroup("peer-fallback", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		if v, ok := db[key]; ok {
+			return []byte(v), nil
+		}
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}))
+	peer := &brokenPeer{err: fromStatus(status.Error(codes.Unavailable, "connection refused"))}
+	g.RegisterPeers(fakePicker{peer: peer})
+
+	// peer 不可用，退化为本地加载
+	if v, err := g.Get("Tom"); err != nil || v.String() != "630" {
+		t.Fatalf("expected local fallback 630, got %v (err=%v)", v, err)
+	}
+	if peer.calls != 1 || loads != 1 {
+		t.Fatalf("expected one peer call and one local load, got %d and %d", peer.calls, loads)
+	}
+
+	// owner 返回 not found，不再本地加载
+	peer.err = fromStatus(status.Error(codes.NotFound, "key not found: Kaido"))
+	if _, err := g.Get("Kaido"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if loads != 1 {
+		t.Fatalf("not found from the owner should not load locally, loads = %d", loads)
+	}
+
+	// owner 的 Getter 已经失败，不再访问一次数据源
+	peer.err = fromStatus(toStatus(loaderError(errors.New("db down"))))
+	if _, err := g.Get("Jack"); !errors.Is(err, ErrLoaderFailed) {
+		t.Fatalf("expected ErrLoaderFailed, got %v", err)
+	}
+	if loads != 1 {
+		t.Fatalf("loader failure on the owner should not load locally, loads = %d", loads)
+	}
+
+	// peer 超时，本地加载
+	peer.err = fromStatus(status.Error(codes.DeadlineExceeded, "context deadline exceeded"))
+	if v, err := g.Get("Jack"); err != nil || v.String() != db["Jack"] {
+		t.Fatalf("expected local fallback after a peer timeout, got %v (err=%v)", v, err)
+	}
+	if loads != 2 {
+		t.Fatalf("peer timeout should load locally, loads = %d", loads)
+	}
+}
+
+func TestGroupLoaderError(t *testing.T) {
+	dbErr := errors.New("db down")
+	g := NewGroup("loader-error", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return nil, dbErr
+	}))
+	_, err := g.Get("Tom")
+	if !errors.Is(err, ErrLoaderFailed) || !errors.Is(err, dbErr) {
+		t.Fatalf("expected loader failure wrapping the getter error, got %v", err)
+	}
+	if _, err := g.Get(""); err != ErrKeyRequired {
+		t.Fatalf("expected ErrKeyRequired, got %v", err)
+	}
+}
+
+func TestNewGroupWithOptions(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte("v-" + key), nil
+	})
+	invalid := [][]GroupOption{
+		{WithK(0)},
+		{WithDefaultTTL(-time.Second)},
+		{WithTTLJitter(lru.Jitter{Policy: lru.UniformJitter, Percent: 1.5})},
+		{WithEvictionInterval(-time.Second)},
+		{WithHotCacheBytes(-1)},
+		{WithPeerTimeout(-time.Second)},
+		{WithEvictionPolicy(lru.PolicyType(-1))},
+		{WithShards(-1)},
+		{WithReadBuffer(-1)},
+	}
+	for i, opts := range invalid {
+		if _, err := NewGroupWithOptions("options-invalid", 2<<10, getter, opts...); !errors.Is(err, ErrInvalidOption) {
+			t.Fatalf("case %d: expected ErrInvalidOption, got %v", i, err)
+		}
+	}
+	if _, err := NewGroupWithOptions("options-invalid", 2<<10, nil); !errors.Is(err, ErrInvalidOption) {
+		t.Fatalf("expected ErrInvalidOption for nil getter, got %v", err)
+	}
+	if GetGroup("options-invalid") != nil {
+		t.Fatal("invalid group should not be registered")
+	}
+
+	var (
+		mu      sync.Mutex
+		evicted []string
+	)
+	g, err := NewGroupWithOptions("options", 2<<10, getter,
+		WithK(3),
+		WithDefaultTTL(100*time.Millisecond),
+		WithEvictionInterval(20*time.Millisecond),
+		WithOnEvicted(func(key string, value ByteView) {
+			// 回调在分片的锁内执行，不同分片可能并发
+			mu.Lock()
+			evicted = append(evicted, key+"="+value.String())
+			mu.Unlock()
+		}),
+		WithHotCacheBytes(1<<10),
+		WithPeerTimeout(time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.stop()
+	if GetGroup("options") != g || g.hotCache == nil || g.hotCache.maxBytes != 1<<10 || g.mainCache.k != 3 {
+		t.Fatal("group should be registered with its options")
+	}
+
+	if v, err := g.Get("Tom"); err != nil || v.String() != "v-Tom" {
+		t.Fatalf("expected v-Tom, got %v (err=%v)", v, err)
+	}
+
+	// 过期后由后台协程清理，并触发回调
+	time.Sleep(200 * time.Millisecond)
+	mu.Lock()
+	got := append([]string(nil), evicted...)
+	mu.Unlock()
+	if len(got) != 1 || got[0] != "Tom=v-Tom" {
+		t.Fatalf("expected Tom to expire once, got %v", got)
+	}
+}
+
+func TestGroupNoExpiration(t *testing.T) {
+	g, err := NewGroupWithOptions("no-expiration", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("v"), nil
+	}), WithDefaultTTL(NoExpiration))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.stop()
+	g.Get("Tom")
+	time.Sleep(2100 * time.Millisecond)
+	if _, ok := g.mainCache.get("Tom"); !ok {
+		t.Fatal("Tom should never expire")
+	}
+}
+
+// slowPeer blocks until the ctx of the call is done
+type slowPeer struct {
+	fakePeer
+}
+
+func (s *slowPeer) Get(ctx context.Context, in *pb.Request, out *pb.Response) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestGroupPeerTimeout(t *testing.T) {
+	g, err := NewGroupWithOptions("peer-timeout", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("local"), nil
+	}), WithPeerTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.RegisterPeers(fakePicker{peer: &slowPeer{}})
+
+	start := time.Now()
+	v, err := g.Get("Tom")
+	if err != nil || v.String() != "local" {
+		t.Fatalf("expected local fallback after the peer timed out, got %v (err=%v)", v, err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("peer timeout not applied, took %v", d)
+	}
+}
+
+func TestGroupEvictionPolicy(t *testing.T) {
+	g, err := NewGroupWithOptions("policy-fifo", 3*len("k0v0"), GetterFunc(func(key string) ([]byte, error) {
+		return []byte("v" + key[1:]), nil
+	}), WithEvictionPolicy(lru.PolicyFIFO))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.stop()
+
+	for _, key := range []string{"k0", "k1", "k2"} {
+		g.Get(key)
+	}
+	// FIFO 不因访问调整顺序，k0 仍然最先被淘汰
+	g.Get("k0")
+	g.Get("k3")
+	if stats := g.CacheStats(MainCache); stats.Items != 3 || stats.Evictions != 1 || stats.Promotions != 0 {
+		t.Fatalf("unexpected main cache stats: %+v", stats)
+	}
+	if _, ok := g.mainCache.get("k0"); ok {
+		t.Fatal("k0 should be evicted first")
+	}
+}
+
+func TestGroupLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.Slog(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	g, err := NewGroupWithOptions("logger", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("local"), nil
+	}), WithPeerTimeout(10*time.Millisecond), WithLogger(l))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.stop()
+	g.RegisterPeers(fakePicker{peer: &slowPeer{}})
+
+	if _, err := g.Get("Tom"); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{"level=WARN", "get from peer failed", "group=logger", "key=Tom"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in %s", want, out)
+		}
+	}
+
+	// 命中缓存不输出日志
+	buf.Reset()
+	for i := 0; i < 3; i++ {
+		g.Get("Tom")
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("cache hits should not log, got %s", buf.String())
+	}
+}
+
+func TestGroupGetterWithTTL(t *testing.T) {
+	ttls := map[string]time.Duration{
+		"reference": NoExpiration,
+		"volatile":  100 * time.Millisecond,
+		"default":   0,
+	}
+	g, err := NewGroupWithOptions("getter-ttl", 2<<10, GetterWithTTLFunc(func(ctx context.Context, key string) ([]byte, time.Duration, error) {
+		ttl, ok := ttls[key]
+		if !ok {
+			return nil, 0, fmt.Errorf("%w: %s", ErrNotFound, key)
+		}
+		return []byte("v-" + key), ttl, nil
+	}), WithDefaultTTL(300*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.stop()
+	for key := range ttls {
+		if v, err := g.Get(key); err != nil || v.String() != "v-"+key {
+			t.Fatalf("Get(%s) = %v, %v", key, v, err)
+		}
+	}
+
+	cached := func(key string) bool {
+		_, ok := g.mainCache.get(key)
+		return ok
+	}
+	time.Sleep(200 * time.Millisecond)
+	if cached("volatile") || !cached("default") || !cached("reference") {
+		t.Fatal("only volatile should have expired")
+	}
+	time.Sleep(200 * time.Millisecond)
+	if cached("default") || !cached("reference") {
+		t.Fatal("default should expire with the group ttl, reference never")
+	}
+}
+
+func TestGroupTTLJitter(t *testing.T) {
+	g, err := NewGroupWithOptions("ttl-jitter", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("v"), nil
+	}), WithDefaultTTL(200*time.Millisecond), WithTTLJitter(lru.Jitter{Policy: lru.UniformJitter, Percent: 0.9}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.stop()
+	for i := 0; i < 50; i++ {
+		g.Get(fmt.Sprintf("key-%d", i))
+	}
+	// 同时加载的数据分散过期：一部分已经过期，一部分还在
+	time.Sleep(200 * time.Millisecond)
+	left := 0
+	for i := 0; i < 50; i++ {
+		if _, ok := g.mainCache.get(fmt.Sprintf("key-%d", i)); ok {
+			left++
+		}
+	}
+	if left == 0 || left == 50 {
+		t.Fatalf("expected keys to expire at different times, %d of 50 left", left)
+	}
+}
+
+func TestGroupNegativeCache(t *testing.T) {
+	loads := 0
+	g, err := NewGroupWithOptions("negative-cache", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		if v, ok := db[key]; ok {
+			return []byte(v), nil
+		}
+		if key == "flaky" {
+			return nil, errors.New("db down")
+		}
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}), WithNegativeCache(100*time.Millisecond, 1<<10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.stop()
+
+	for i := 0; i < 3; i++ {
+		if _, err := g.Get("Kaido"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("missing key should be loaded once, loads = %d", loads)
+	}
+
+	// 其他错误不缓存
+	g.Get("flaky")
+	g.Get("flaky")
+	if loads != 3 {
+		t.Fatalf("loader failures should not be cached, loads = %d", loads)
+	}
+
+	// 过期后重新加载
+	time.Sleep(150 * time.Millisecond)
+	g.Get("Kaido")
+	if loads != 4 {
+		t.Fatalf("missing key should be reloaded after the negative ttl, loads = %d", loads)
+	}
+
+	// Set 之后不再返回 not found
+	if err := g.Set("Kaido", []byte("999"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := g.Get("Kaido"); err != nil || v.String() != "999" {
+		t.Fatalf("expected 999 after Set, got %v (err=%v)", v, err)
+	}
+
+	if _, err := NewGroupWithOptions("negative-cache-invalid", 2<<10, g.getter, WithNegativeCache(time.Second, 0)); !errors.Is(err, ErrInvalidOption) {
+		t.Fatalf("expected ErrInvalidOption, got %v", err)
+	}
+}
+
+func TestGroupStaleWhileRevalidate(t *testing.T) {
+	var mu sync.Mutex
+	version := 0
+	release := make(chan struct{})
+	g, err := NewGroupWithOptions("stale", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		mu.Lock()
+		version++
+		v := version
+		mu.Unlock()
+		if v > 1 {
+			<-release
+		}
+		return []byte(fmt.Sprintf("v%d", v)), nil
+	}), WithDefaultTTL(200*time.Millisecond), WithStaleWhileRevalidate(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.stop()
+
+	if v, _ := g.Get("Tom"); v.String() != "v1" {
+		t.Fatalf("expected v1, got %s", v)
+	}
+	time.Sleep(250 * time.Millisecond)
+
+	// 过期后立即返回旧值，只触发一次后台刷新
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := g.Get("Tom"); err != nil || v.String() != "v1" {
+				t.Errorf("expected stale v1, got %s (err=%v)", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(release)
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		if v, _ := g.Get("Tom"); v.String() == "v2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("stale entry should be refreshed in the background")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if version != 2 {
+		t.Fatalf("expected a single refresh, getter called %d times", version)
+	}
+}
+
+// countingPeer counts the Get calls reaching fakePeer
+type countingPeer struct {
+	fakePeer
+	gets int
+}
+
+func (c *countingPeer) Get(ctx context.Context, in *pb.Request, out *pb.Response) error {
+	c.gets++
+	return c.fakePeer.Get(ctx, in, out)
+}
+
+func TestHotCacheTTL(t *testing.T) {
+	tests := []struct {
+		ttl, hotTTL, want time.Duration
+	}{
+		{0, 0, 0},
+		{time.Second, 0, time.Second},
+		{time.Hour, 0, maxDefaultHotCacheTTL},
+		{NoExpiration, 0, maxDefaultHotCacheTTL}, // 其他节点的 Set 和 Delete 最终要可见
+		{NoExpiration, time.Hour, time.Hour},
+	}
+	for _, tt := range tests {
+		o := &groupOptions{ttl: tt.ttl, hotCacheTTL: tt.hotTTL}
+		if got := o.hotTTL(); got != tt.want {
+			t.Errorf("hotTTL() with ttl %v and hot ttl %v = %v, want %v", tt.ttl, tt.hotTTL, got, tt.want)
+		}
+	}
+}
+
+func TestGroupHotCache(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	})
+	g, err := NewGroupWithOptions("hot-cache", 2<<10, getter,
+		WithHotCacheBytes(1<<10), WithHotCacheSampleRate(1), WithHotCacheTTL(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.stop()
+	peer := &countingPeer{fakePeer: fakePeer{sets: map[string][]byte{"Tom": []byte("630")}}}
+	g.RegisterPeers(fakePicker{peer: peer})
+
+	for i := 0; i < 3; i++ {
+		if v, err := g.Get("Tom"); err != nil || v.String() != "630" {
+			t.Fatalf("expected 630, got %v (err=%v)", v, err)
+		}
+	}
+	if peer.gets != 1 {
+		t.Fatalf("hot key should be served from the hot cache, peer gets = %d", peer.gets)
+	}
+	if _, ok := g.mainCache.get("Tom"); ok {
+		t.Fatal("values of other peers should not go to mainCache")
+	}
+
+	// Set 和 Delete 使本地副本失效
+	g.Set("Tom", []byte("631"), 0)
+	if v, _ := g.Get("Tom"); v.String() != "631" || peer.gets != 2 {
+		t.Fatalf("Set should invalidate the hot copy, got %s, peer gets = %d", v, peer.gets)
+	}
+	g.Delete("Tom")
+	if _, err := g.Get("Tom"); err == nil || peer.gets != 3 {
+		t.Fatalf("Delete should invalidate the hot copy, err = %v, peer gets = %d", err, peer.gets)
+	}
+
+	// 采样率为 0 时不缓存
+	g2, err := NewGroupWithOptions("hot-cache-no-sample", 2<<10, getter,
+		WithHotCacheBytes(1<<10), WithHotCacheSampleRate(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g2.stop()
+	peer2 := &countingPeer{fakePeer: fakePeer{sets: map[string][]byte{"Tom": []byte("630")}}}
+	g2.RegisterPeers(fakePicker{peer: peer2})
+	g2.Get("Tom")
+	g2.Get("Tom")
+	if peer2.gets != 2 {
+		t.Fatalf("nothing should be sampled, peer gets = %d", peer2.gets)
+	}
+
+	if _, err := NewGroupWithOptions("hot-cache-invalid", 2<<10, getter, WithHotCacheSampleRate(2)); !errors.Is(err, ErrInvalidOption) {
+		t.Fatalf("expected ErrInvalidOption, got %v", err)
+	}
+}
@@ -0,0 +1,335 @@
+package mygroupcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"my_groupcache/bloom"
+	"my_groupcache/registry"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// testCluster is a set of in-process nodes talking grpc over bufconn
+type testCluster struct {
+	addrs     []string
+	listeners map[string]*bufconn.Listener
+	pools     map[string]*GRPCPool
+	groups    map[string]*Group
+	registry  *registry.Memory
+
+	name   string
+	getter func(key string) ([]byte, error)
+	dial   func(addr string) (*grpc.ClientConn, error)
+
+	mu    sync.Mutex
+	loads map[string][]string // addr -> keys loaded by the getter of that node
+}
+
+func newTestCluster(t *testing.T, name string, n int, getter func(key string) ([]byte, error)) *testCluster {
+	tc := &testCluster{
+		listeners: make(map[string]*bufconn.Listener),
+		pools:     make(map[string]*GRPCPool),
+		groups:    make(map[string]*Group),
+		loads:     make(map[string][]string),
+	}
+	for i := 0; i < n; i++ {
+		addr := fmt.Sprintf("node-%d", i)
+		tc.addrs = append(tc.addrs, addr)
+		tc.listeners[addr] = bufconn.Listen(1 << 20)
+	}
+	dial := func(addr string) (*grpc.ClientConn, error) {
+		lis, ok := tc.listeners[addr]
+		if !ok {
+			return nil, fmt.Errorf("unknown addr %s", addr)
+		}
+		return grpc.NewClient("passthrough:///"+addr,
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return lis.DialContext(ctx)
+			}),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+	}
+	tc.name, tc.getter, tc.dial = name, getter, dial
+	tc.registry = registry.NewMemory()
+	for _, addr := range tc.addrs {
+		tc.startNode(addr)
+	}
+	t.Cleanup(func() {
+		for _, pool := range tc.pools {
+			pool.Close()
+		}
+		for _, lis := range tc.listeners {
+			lis.Close()
+		}
+	})
+	for _, addr := range tc.addrs {
+		waitPeers(t, tc.pools[addr], tc.addrs...)
+	}
+	return tc
+}
+
+// startNode starts a node which finds its peers through tc.registry
+func (tc *testCluster) startNode(addr string) {
+	pool := NewGRPCPool(addr, 0, nil, WithRegistry(tc.registry))
+	pool.dial = tc.dial
+	g := NewGroup(tc.name, 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		tc.mu.Lock()
+		tc.loads[addr] = append(tc.loads[addr], key)
+		tc.mu.Unlock()
+		return tc.getter(key)
+	}))
+	g.RegisterPeers(pool)
+	tc.pools[addr] = pool
+	tc.groups[addr] = g
+	go pool.serve(tc.listeners[addr])
+}
+
+// owner returns the node that owns key on the ring
+func (tc *testCluster) owner(key string) string {
+	return tc.pools[tc.addrs[0]].peers.Get(key)
+}
+
+func (tc *testCluster) loadsOf(addr string) []string {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	return append([]string(nil), tc.loads[addr]...)
+}
+
+func TestClusterGetFromPeer(t *testing.T) {
+	values := map[string][]byte{
+		"Tom":    []byte("630"),
+		"Jack":   []byte("589"),
+		"Sam":    []byte("567"),
+		"Nami":   []byte("679"),
+		"binary": {0x0a, 0x00, 0xff, 0x12, 0x03}, // looks like a protobuf message
+	}
+	tc := newTestCluster(t, "cluster-get", 3, func(key string) ([]byte, error) {
+		if v, ok := values[key]; ok {
+			return v, nil
+		}
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	})
+
+	for _, from := range tc.addrs {
+		for key, want := range values {
+			v, err := tc.groups[from].Get(key)
+			if err != nil {
+				t.Fatalf("%s: Get(%s) failed: %v", from, key, err)
+			}
+			if string(v.ByteSlice()) != string(want) {
+				t.Fatalf("%s: Get(%s) = %v, want %v", from, key, v.ByteSlice(), want)
+			}
+		}
+	}
+
+	// 每个 key 只会被 owner 的 getter 加载
+	for key := range values {
+		owner := tc.owner(key)
+		for _, addr := range tc.addrs {
+			for _, loaded := range tc.loadsOf(addr) {
+				if loaded == key && addr != owner {
+					t.Errorf("key %s loaded on %s, owner is %s", key, addr, owner)
+				}
+			}
+		}
+	}
+}
+
+func TestClusterNotFound(t *testing.T) {
+	tc := newTestCluster(t, "cluster-not-found", 3, func(key string) ([]byte, error) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	})
+	owner := tc.owner("Kaido")
+	for _, from := range tc.addrs {
+		if _, err := tc.groups[from].Get("Kaido"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("%s: expected ErrNotFound, got %v", from, err)
+		}
+	}
+	for _, addr := range tc.addrs {
+		if addr != owner && len(tc.loadsOf(addr)) != 0 {
+			t.Errorf("%s is not the owner but loaded %v", addr, tc.loadsOf(addr))
+		}
+	}
+}
+
+func TestClusterMembership(t *testing.T) {
+	tc := newTestCluster(t, "cluster-membership", 2, func(key string) ([]byte, error) {
+		return []byte("v-" + key), nil
+	})
+
+	// 扩容：新节点注册后，所有节点都能感知到
+	tc.addrs = append(tc.addrs, "node-2")
+	tc.listeners["node-2"] = bufconn.Listen(1 << 20)
+	tc.startNode("node-2")
+	for _, addr := range tc.addrs {
+		waitPeers(t, tc.pools[addr], tc.addrs...)
+	}
+	owned := 0
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if tc.owner(key) == "node-2" {
+			owned++
+			if v, err := tc.groups["node-0"].Get(key); err != nil || v.String() != "v-"+key {
+				t.Fatalf("Get(%s) = %v, %v", key, v, err)
+			}
+		}
+	}
+	if owned == 0 {
+		t.Fatal("new node should own some keys")
+	}
+
+	// 缩容：节点下线后从哈希环中移除
+	tc.registry.Deregister(context.Background(), "groupcache", "node-1")
+	waitPeers(t, tc.pools["node-0"], "node-0", "node-2")
+	waitPeers(t, tc.pools["node-2"], "node-0", "node-2")
+}
+
+func TestClusterStop(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	tc := newTestCluster(t, "cluster-stop", 2, func(key string) ([]byte, error) {
+		if key == "slow" {
+			started <- struct{}{}
+			<-release
+		}
+		return []byte("v-" + key), nil
+	})
+	owner := tc.owner("slow")
+	other := tc.addrs[0]
+	if other == owner {
+		other = tc.addrs[1]
+	}
+	// 让 owner 上有缓存数据，启动清理协程
+	tc.groups[owner].Get("slow-warmup")
+
+	// 一个正在处理中的远程请求
+	result := make(chan error, 1)
+	go func() {
+		v, err := tc.groups[other].Get("slow")
+		if err == nil && v.String() != "v-slow" {
+			err = fmt.Errorf("got %s", v.String())
+		}
+		result <- err
+	}()
+	<-started
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- tc.pools[owner].Stop(context.Background())
+	}()
+
+	// 先摘除节点，再等待请求结束
+	waitPeers(t, tc.pools[other], other)
+	select {
+	case <-stopped:
+		t.Fatal("Stop should wait for the in-flight call")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	if err := <-result; err != nil {
+		t.Fatalf("in-flight call should be drained, got %v", err)
+	}
+	if err := <-stopped; err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+
+	g := tc.groups[owner]
+	g.mainCache.mu.Lock()
+	running := g.mainCache.evictionRunning
+	g.mainCache.mu.Unlock()
+	if running {
+		t.Fatal("Stop should stop the eviction loop of the groups")
+	}
+	if _, _, err := tc.pools[owner].conns.acquire(other); !errors.Is(err, ErrPeerUnavailable) {
+		t.Fatalf("connections should be closed after Stop, got %v", err)
+	}
+}
+
+func TestClusterStopDeadline(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{}, 1)
+	tc := newTestCluster(t, "cluster-stop-deadline", 2, func(key string) ([]byte, error) {
+		started <- struct{}{}
+		<-release
+		return []byte("v"), nil
+	})
+	owner := tc.owner("stuck")
+	other := tc.addrs[0]
+	if other == owner {
+		other = tc.addrs[1]
+	}
+	go tc.groups[other].Get("stuck")
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := tc.pools[owner].Stop(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestClusterStopAbortsHandlers(t *testing.T) {
+	started := make(chan struct{}, 1)
+	aborted := make(chan error, 1)
+	tc := newTestCluster(t, "cluster-stop-abort", 2, func(key string) ([]byte, error) {
+		return []byte("v"), nil
+	})
+	owner := tc.owner("stuck")
+	other := tc.addrs[0]
+	if other == owner {
+		other = tc.addrs[1]
+	}
+	tc.groups[owner].getter = ContextGetterFunc(func(ctx context.Context, key string) ([]byte, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		aborted <- ctx.Err()
+		return nil, ctx.Err()
+	})
+	go tc.groups[other].GetContext(context.Background(), "stuck")
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := tc.pools[owner].Stop(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+	select {
+	case err := <-aborted:
+		if err != context.Canceled {
+			t.Fatalf("handler ctx err = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler ctx should be canceled once Stop gives up")
+	}
+}
+
+func TestClusterShipBloomFilter(t *testing.T) {
+	tc := newTestCluster(t, "cluster-bloom", 2, func(key string) ([]byte, error) {
+		return []byte("v"), nil
+	})
+	from, to := tc.addrs[0], tc.addrs[1]
+	f := bloom.New(100, 0.01)
+	f.Add("Tom")
+	g := tc.groups[from]
+	g.bloom = newBloomGuard(BloomOptions{})
+	g.bloom.filter.Store(f)
+
+	// to 没有 key 枚举，从其他节点获取
+	g = tc.groups[to]
+	g.bloom = newBloomGuard(BloomOptions{})
+	defer g.bloom.close()
+	if err := g.rebuildBloom(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := g.bloomFilter(); got == nil || !got.Test("Tom") || got.K() != f.K() {
+		t.Fatal("filter should be shipped from the peer")
+	}
+}
@@ -0,0 +1,89 @@
+// leveled, structured logging used by the cache, backed by log/slog or nothing
+package logger
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Logger logs a message with key-value pairs, e.g.
+// l.Warn("load from peer failed", "group", "scores", "key", key, "err", err)
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// Nop returns a Logger that discards everything, it is the default
+func Nop() Logger { return nop{} }
+
+type nop struct{}
+
+func (nop) Debug(string, ...any) {}
+func (nop) Info(string, ...any)  {}
+func (nop) Warn(string, ...any)  {}
+func (nop) Error(string, ...any) {}
+
+// OrNop returns l, or Nop if l is nil
+func OrNop(l Logger) Logger {
+	if l == nil {
+		return nop{}
+	}
+	return l
+}
+
+// Slog adapts l to a Logger, nil means slog.Default()
+func Slog(l *slog.Logger) Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return slogLogger{l}
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (s slogLogger) Debug(msg string, args ...any) { s.log(slog.LevelDebug, msg, args) }
+func (s slogLogger) Info(msg string, args ...any)  { s.log(slog.LevelInfo, msg, args) }
+func (s slogLogger) Warn(msg string, args ...any)  { s.log(slog.LevelWarn, msg, args) }
+func (s slogLogger) Error(msg string, args ...any) { s.log(slog.LevelError, msg, args) }
+
+func (s slogLogger) log(level slog.Level, msg string, args []any) {
+	// 先判断级别，关闭的级别不会格式化参数
+	if !s.l.Enabled(context.Background(), level) {
+		return
+	}
+	s.l.Log(context.Background(), level, msg, args...)
+}
+
+func (s slogLogger) with(args []any) Logger { return slogLogger{s.l.With(args...)} }
+
+// With returns a Logger that adds args to every message of l
+func With(l Logger, args ...any) Logger {
+	switch l := l.(type) {
+	case nil, nop:
+		return nop{}
+	case slogLogger:
+		return l.with(args)
+	case withLogger:
+		return withLogger{l.l, append(append([]any(nil), l.args...), args...)}
+	}
+	return withLogger{l, args}
+}
+
+// withLogger adds fields to a Logger that is not backed by slog
+type withLogger struct {
+	l    Logger
+	args []any
+}
+
+func (w withLogger) Debug(msg string, args ...any) { w.l.Debug(msg, w.merge(args)...) }
+func (w withLogger) Info(msg string, args ...any)  { w.l.Info(msg, w.merge(args)...) }
+func (w withLogger) Warn(msg string, args ...any)  { w.l.Warn(msg, w.merge(args)...) }
+func (w withLogger) Error(msg string, args ...any) { w.l.Error(msg, w.merge(args)...) }
+
+func (w withLogger) merge(args []any) []any {
+	return append(append(make([]any, 0, len(w.args)+len(args)), w.args...), args...)
+}
@@ -0,0 +1,57 @@
+package logger
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlog(t *testing.T) {
+	var buf bytes.Buffer
+	l := Slog(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+	l = With(l, "group", "scores")
+	l.Debug("cache hit", "key", "Tom")
+	l.Warn("load failed", "key", "Tom", "peer", "node-1")
+
+	out := buf.String()
+	if strings.Contains(out, "cache hit") {
+		t.Fatalf("debug message logged at info level: %s", out)
+	}
+	for _, want := range []string{"level=WARN", `msg="load failed"`, "group=scores", "key=Tom", "peer=node-1"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in %s", want, out)
+		}
+	}
+}
+
+type recorder struct {
+	msgs [][]any
+}
+
+func (r *recorder) Debug(msg string, args ...any) {
+	r.msgs = append(r.msgs, append([]any{msg}, args...))
+}
+func (r *recorder) Info(msg string, args ...any)  { r.Debug(msg, args...) }
+func (r *recorder) Warn(msg string, args ...any)  { r.Debug(msg, args...) }
+func (r *recorder) Error(msg string, args ...any) { r.Debug(msg, args...) }
+
+func TestWith(t *testing.T) {
+	r := &recorder{}
+	l := With(With(r, "group", "scores"), "peer", "node-1")
+	l.Info("picked", "key", "Tom")
+	want := []any{"picked", "group", "scores", "peer", "node-1", "key", "Tom"}
+	if len(r.msgs) != 1 || len(r.msgs[0]) != len(want) {
+		t.Fatalf("got %v, want %v", r.msgs, want)
+	}
+	for i := range want {
+		if r.msgs[0][i] != want[i] {
+			t.Fatalf("got %v, want %v", r.msgs[0], want)
+		}
+	}
+
+	if _, ok := With(nil, "a", 1).(nop); !ok {
+		t.Fatal("With(nil) should be a no-op logger")
+	}
+	OrNop(nil).Error("dropped")
+}
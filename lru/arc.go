@@ -0,0 +1,134 @@
+package lru
+
+import "container/list"
+
+// lists of ARC
+const (
+	arcT1 = iota // 最近只访问过一次的 key
+	arcT2        // 访问过至少两次的 key
+	arcB1        // 从 T1 淘汰的 key，只保留 key 和大小
+	arcB2        // 从 T2 淘汰的 key
+)
+
+type arcItem struct {
+	key  string
+	size int64
+	list int
+}
+
+// arcPolicy is the Adaptive Replacement Cache with sizes in bytes: T1 holds
+// keys seen once and T2 keys seen at least twice, B1 and B2 remember the keys
+// recently evicted from them. A miss that hits B1 means T1 was too small and
+// grows its target size p, a miss that hits B2 shrinks it. Evictions take
+// from T1 while it is larger than p, from T2 otherwise.
+type arcPolicy struct {
+	lists [4]*list.List // 最近访问的在前
+	bytes [4]int64
+	items map[string]*list.Element
+
+	capacity int64 // 缓存容量，即 maxBytes
+	p        int64 // T1 的目标大小
+}
+
+// NewARCPolicy creates an ARC policy for a store of maxBytes, 0 maxBytes means
+// no limit and keeps no ghost entries
+func NewARCPolicy(maxBytes int64) EvictionPolicy {
+	p := &arcPolicy{items: make(map[string]*list.Element), capacity: maxBytes}
+	for i := range p.lists {
+		p.lists[i] = list.New()
+	}
+	return p
+}
+
+func (p *arcPolicy) Add(key string, size int64) {
+	ele, ok := p.items[key]
+	if !ok {
+		p.items[key] = p.lists[arcT1].PushFront(&arcItem{key: key, size: size, list: arcT1})
+		p.bytes[arcT1] += size
+		p.trimGhosts()
+		return
+	}
+	item := ele.Value.(*arcItem)
+	switch item.list {
+	case arcB1:
+		// 刚从 T1 淘汰又被访问，T1 应该更大
+		p.p = min(p.p+p.delta(size, arcB2, arcB1), p.capacity)
+	case arcB2:
+		p.p = max(p.p-p.delta(size, arcB1, arcB2), 0)
+	}
+	p.bytes[item.list] -= item.size
+	item.size = size
+	p.bytes[item.list] += size
+	p.moveTo(ele, arcT2)
+	p.trimGhosts()
+}
+
+// delta is how much p moves on a ghost hit of size bytes in list hit,
+// it is larger when the other ghost list is larger
+func (p *arcPolicy) delta(size int64, other, hit int) int64 {
+	if p.bytes[other] > p.bytes[hit] && p.bytes[hit] > 0 {
+		return size * p.bytes[other] / p.bytes[hit]
+	}
+	return size
+}
+
+func (p *arcPolicy) Access(key string) {
+	if ele, ok := p.items[key]; ok {
+		if list := ele.Value.(*arcItem).list; list == arcT1 || list == arcT2 {
+			p.moveTo(ele, arcT2)
+		}
+	}
+}
+
+func (p *arcPolicy) Remove(key string) {
+	if ele, ok := p.items[key]; ok {
+		if list := ele.Value.(*arcItem).list; list == arcT1 || list == arcT2 {
+			p.unlink(ele)
+		}
+	}
+}
+
+// Evict moves the least recently used key of T1 or T2 to its ghost list
+func (p *arcPolicy) Evict() (string, bool) {
+	from, ghost := arcT2, arcB2
+	if t1 := p.lists[arcT1].Len(); t1 > 0 && (p.bytes[arcT1] > p.p || p.lists[arcT2].Len() == 0) {
+		from, ghost = arcT1, arcB1
+	}
+	ele := p.lists[from].Back()
+	if ele == nil {
+		return "", false
+	}
+	key := ele.Value.(*arcItem).key
+	if p.capacity > 0 {
+		p.moveTo(ele, ghost)
+	} else {
+		p.unlink(ele)
+	}
+	p.trimGhosts()
+	return key, true
+}
+
+// trimGhosts keeps T1+B1 within the capacity and all lists within twice the capacity
+func (p *arcPolicy) trimGhosts() {
+	for p.bytes[arcT1]+p.bytes[arcB1] > p.capacity && p.lists[arcB1].Len() > 0 {
+		p.unlink(p.lists[arcB1].Back())
+	}
+	for p.bytes[arcT1]+p.bytes[arcT2]+p.bytes[arcB1]+p.bytes[arcB2] > 2*p.capacity && p.lists[arcB2].Len() > 0 {
+		p.unlink(p.lists[arcB2].Back())
+	}
+}
+
+func (p *arcPolicy) moveTo(ele *list.Element, to int) {
+	item := p.unlink(ele)
+	item.list = to
+	p.items[item.key] = p.lists[to].PushFront(item)
+	p.bytes[to] += item.size
+}
+
+func (p *arcPolicy) unlink(ele *list.Element) *arcItem {
+	item := ele.Value.(*arcItem)
+	p.lists[item.list].Remove(ele)
+	p.bytes[item.list] -= item.size
+	delete(p.items, item.key)
+	return item
+}
@@ -0,0 +1,97 @@
+package lru
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestARCGhostHits(t *testing.T) {
+	p := NewARCPolicy(4).(*arcPolicy)
+	for _, key := range []string{"a", "b", "c", "d"} {
+		p.Add(key, 1)
+	}
+	p.Access("c")
+	p.Access("d")
+	// T1: b a, T2: d c
+	if key, _ := p.Evict(); key != "a" {
+		t.Fatalf("Evict() = %s, want a from T1", key)
+	}
+	if p.lists[arcB1].Len() != 1 {
+		t.Fatal("a should be remembered in B1")
+	}
+
+	// B1 命中，T1 的目标变大，a 进入 T2
+	p.Add("a", 1)
+	if p.p != 1 || p.items["a"].Value.(*arcItem).list != arcT2 {
+		t.Fatalf("p = %d after a B1 hit, want 1", p.p)
+	}
+
+	// T1 不超过目标时从 T2 淘汰
+	if key, _ := p.Evict(); key != "c" {
+		t.Fatalf("Evict() = %s, want c from T2", key)
+	}
+	p.Add("c", 1)
+	if p.p != 0 {
+		t.Fatalf("p = %d after a B2 hit, want 0", p.p)
+	}
+}
+
+func TestARCRemoveForgets(t *testing.T) {
+	p := NewARCPolicy(2).(*arcPolicy)
+	p.Add("a", 1)
+	p.Add("b", 1)
+	p.Evict()
+	p.Remove("a") // 幽灵条目不受 Remove 影响
+	if p.lists[arcB1].Len() != 1 {
+		t.Fatal("Remove should not drop ghost entries")
+	}
+	p.Remove("b")
+	if _, ok := p.Evict(); ok || len(p.items) != 1 {
+		t.Fatalf("b should be removed without a ghost, items %d", len(p.items))
+	}
+}
+
+func TestARCGhostBytes(t *testing.T) {
+	const maxBytes = 100
+	s := New(PolicyARC, 0, maxBytes, nil).(*baseCache)
+	p := s.policy.(*arcPolicy)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("k%03d", i%300)
+		if _, ok := s.Get(key); !ok {
+			s.AddWithExpire(key, String("v"), 0)
+		}
+		if total := p.bytes[arcT1] + p.bytes[arcT2] + p.bytes[arcB1] + p.bytes[arcB2]; total > 2*maxBytes {
+			t.Fatalf("lists hold %d bytes, more than twice the capacity", total)
+		}
+		if p.bytes[arcT1]+p.bytes[arcB1] > maxBytes || p.p < 0 || p.p > maxBytes {
+			t.Fatalf("T1+B1 = %d, p = %d", p.bytes[arcT1]+p.bytes[arcB1], p.p)
+		}
+		if p.bytes[arcT1]+p.bytes[arcT2] != s.usedBytes {
+			t.Fatalf("policy holds %d bytes, store %d", p.bytes[arcT1]+p.bytes[arcT2], s.usedBytes)
+		}
+	}
+}
+
+func TestARCScanResistance(t *testing.T) {
+	s := New(PolicyARC, 0, 100*10, nil)
+	hot := make([]string, 50)
+	for i := range hot {
+		hot[i] = fmt.Sprintf("hot%02d", i)
+	}
+	for round := 0; round < 2; round++ {
+		for _, key := range hot {
+			if _, ok := s.Get(key); !ok {
+				s.AddWithExpire(key, String("abcde"), 0)
+			}
+		}
+	}
+	// 只访问一次的 key 只会挤占 T1
+	for i := 0; i < 1000; i++ {
+		s.AddWithExpire(fmt.Sprintf("scan%d", i), String("abc"), 0)
+	}
+	for _, key := range hot {
+		if _, ok := s.Get(key); !ok {
+			t.Fatalf("hot key %s was evicted by a scan", key)
+		}
+	}
+}
@@ -1,8 +1,7 @@
 package lru
 
 import (
-	"container/list"
-	"log"
+	"my_groupcache/logger"
 	"time"
 )
 type baseCache struct {
@@ -12,11 +11,11 @@ type baseCache struct {
 	// Has used Bytes
 	usedBytes int64
 
-	// double link list
-	ll    *list.List
+	// 淘汰策略，决定容量不足时淘汰哪条数据
+	policy EvictionPolicy
 
 	// for O(1) Get
-	cache map[string]*list.Element
+	cache map[string]*entry
 
 	// expire time map
 	expires map[string] time.Time
@@ -24,8 +23,24 @@ type baseCache struct {
 	// default expire time
 	expireTime time.Duration
 
+	// 插入时给过期时间加上随机抖动
+	jitter Jitter
+
+	// 过期后继续保留的时间，期间可以通过 GetStale 读到旧值
+	grace time.Duration
+
+	// 剩余过期时间小于 refreshAhead*ttl 时被访问，调用 onRefresh
+	refreshAhead float64
+	onRefresh    func(key string) bool
+
 	// 回调函数
 	OnEvicted func(key string, value Value)
+
+	// 统计信息，不加锁也可以读取
+	stats tierCounters
+
+	// 默认不输出日志
+	logger logger.Logger
 }
 
 // Real data that stored in cache
@@ -33,6 +48,12 @@ type entry struct {
 	key   string
 	visit int
 	value Value
+	// 插入时的过期时长，用于判断是否需要提前刷新
+	ttl time.Duration
+	// 已经提交了提前刷新，更新后重置
+	refreshing bool
+	// 所在的 baseCache，删除后为空，用来判断 Handle 是否还有效
+	owner *baseCache
 }
 
 type Value interface{
@@ -40,13 +61,18 @@ type Value interface{
 }
 
 func newBaseCache(maxBytes int64, OnEvicted func(string, Value)) *baseCache {
+	return newPolicyCache(maxBytes, NewLRUPolicy(), OnEvicted)
+}
+
+func newPolicyCache(maxBytes int64, policy EvictionPolicy, OnEvicted func(string, Value)) *baseCache {
 	return &baseCache{
 		maxBytes: maxBytes,
-		ll: list.New(),
-		cache: make(map[string]*list.Element),
+		policy: policy,
+		cache: make(map[string]*entry),
 		expires: make(map[string]time.Time),
 		expireTime: 2000 * time.Millisecond, // 2s
 		OnEvicted: OnEvicted,
+		logger: logger.Nop(),
 	}
 }
 
@@ -56,88 +82,189 @@ func (bc *baseCache) SetExpireTime(expireTime time.Duration) {
 }
 
 
+// SetJitter sets the jitter applied to the expire time of added entries
+func (bc *baseCache) SetJitter(jitter Jitter) {
+	bc.jitter = jitter
+}
+
+// SetGrace keeps expired entries for grace, so that they can still be read by GetStale
+func (bc *baseCache) SetGrace(grace time.Duration) {
+	bc.grace = grace
+}
+
+// SetLogger sets the logger of the cache, nil discards the logs
+func (bc *baseCache) SetLogger(l logger.Logger) {
+	bc.logger = logger.OrNop(l)
+}
+
+// SetRefreshAhead calls fn when an entry is accessed with less than fraction
+// of its ttl left, see Cache.SetRefreshAhead
+func (bc *baseCache) SetRefreshAhead(fraction float64, fn func(key string) bool) {
+	bc.refreshAhead = fraction
+	bc.onRefresh = fn
+}
+
+// maybeRefresh must be called after a hit of kv that has not expired
+func (bc *baseCache) maybeRefresh(kv *entry) {
+	if bc.onRefresh == nil || bc.refreshAhead <= 0 || kv.refreshing || kv.ttl <= 0 {
+		return
+	}
+	deadline, ok := bc.expires[kv.key]
+	if !ok {
+		return
+	}
+	// 已经过期的数据不提前刷新
+	if left := time.Until(deadline); left > 0 && left < time.Duration(bc.refreshAhead*float64(kv.ttl)) {
+		kv.refreshing = bc.onRefresh(kv.key)
+	}
+}
+
+// Stats returns the stats of the cache as a single Hot tier
+func (bc *baseCache) Stats() Stats {
+	return Stats{Hot: bc.stats.snapshot()}
+}
+
 // Get
 func (bc *baseCache) Get(key string) (value Value, ok bool) {
-
-	if (key == "") {
-		log.Println("Get: key == nil")
+	value, stale, ok := bc.GetStale(key)
+	if stale {
+		return nil, false
 	}
+	return value, ok
+}
+
+// GetStale is like Get, but also returns entries that expired less than
+// grace ago, stale reports whether the entry has expired
+func (bc *baseCache) GetStale(key string) (value Value, stale bool, ok bool) {
 
 	// Is it expire?
 	if bc.expires != nil {
 		if expire, ok := bc.expires[key]; ok && !expire.IsZero(){
-			if time.Now().After(expire) {
+			now := time.Now()
+			if now.After(expire.Add(bc.grace)) {
 				// remove
-				log.Println("Expired!")
-				bc.Remove(key)
-				return nil, false
+				bc.expire(key)
+				return nil, false, false
 			}
+			stale = now.After(expire)
 		}
 	}
 
 	// Get from cache
-	if ele, ok := bc.cache[key]; ok { // cache hit
-		log.Printf("lru.go: cache hit!")
+	if kv, ok := bc.cache[key]; ok { // cache hit
 		// get
-		bc.ll.MoveToFront(ele)
-		kv := ele.Value.(*entry)
-		return kv.value, true
+		bc.policy.Access(key)
+		if !stale {
+			bc.maybeRefresh(kv)
+		}
+		return kv.value, stale, true
 	}
 	return 
 }
 
-func (bc *baseCache) RemoveOldest() {
-	ele := bc.ll.Back()
-	if ele != nil {
-		// from map
-		kv := ele.Value.(*entry)
-		delete(bc.cache, kv.key)
+// Peek is like GetStale, but does not touch the eviction policy, remove
+// expired entries or refresh ahead, so it can run concurrently with other Peeks
+func (bc *baseCache) Peek(key string) (h Handle, stale bool, ok bool) {
+	kv, ok := bc.cache[key]
+	if !ok {
+		return Handle{}, false, false
+	}
+	if expire, ok := bc.expires[key]; ok && !expire.IsZero() {
+		now := time.Now()
+		if now.After(expire.Add(bc.grace)) {
+			return Handle{}, false, false
+		}
+		stale = now.After(expire)
+	}
+	return Handle{kv}, stale, true
+}
+
+// Touch applies an access of an entry returned by Peek
+func (bc *baseCache) Touch(h Handle) {
+	if h.e == nil || h.e.owner != bc {
+		// 已经被删除
+		return
+	}
+	bc.touch(h.e)
+}
 
-		// from double-link-list
-		bc.ll.Remove(ele)
-		log.Printf("RemoveOldest cache: key= %s, value= %v\n", kv.key, kv.value)
+// touch is a hit of kv
+func (bc *baseCache) touch(kv *entry) {
+	bc.policy.Access(kv.key)
+	bc.maybeRefresh(kv)
+}
 
-		// resize
-		bc.usedBytes -= int64(len(kv.key) + kv.value.Len())
-		if bc.OnEvicted != nil {
-			bc.OnEvicted(kv.key, kv.value)
-		}
+// RemoveOldest evicts the entry chosen by the eviction policy
+func (bc *baseCache) RemoveOldest() {
+	bc.evictOne()
+}
+
+// evictOne reports whether an entry was evicted
+func (bc *baseCache) evictOne() bool {
+	key, ok := bc.policy.Evict()
+	if !ok {
+		return false
+	}
+	kv, ok := bc.cache[key]
+	if !ok {
+		return true
 	}
+	bc.removeEntry(kv)
+	bc.logger.Debug("evict", "key", kv.key)
+	bc.stats.evictions.Add(1)
+	if bc.OnEvicted != nil {
+		bc.OnEvicted(kv.key, kv.value)
+	}
+	return true
 }
 
 func (bc *baseCache) AddWithExpire(key string, value Value, expire time.Duration) {
+	var deadline time.Time
+	if expire > 0 {
+		expire = bc.jitter.Apply(expire)
+		deadline = time.Now().Add(expire)
+	}
+	bc.addWithDeadline(key, value, deadline, expire)
+}
+
+// addWithDeadline adds a value that expires at deadline, zero deadline means never expire.
+// ttl is the duration the deadline was computed from.
+func (bc *baseCache) addWithDeadline(key string, value Value, deadline time.Time, ttl time.Duration) {
 	if bc.cache == nil {
-		bc.cache = make(map[string]*list.Element)
+		bc.cache = make(map[string]*entry)
 	}
 	if bc.expires == nil {
 		bc.expires = make(map[string]time.Time)
 	}
 	// expire
-	if expire > 0 {
-		bc.expires[key] = time.Now().Add(expire)
+	if !deadline.IsZero() {
+		bc.expires[key] = deadline
+	} else {
+		delete(bc.expires, key)
 	}
-	if ele, ok := bc.cache[key]; ok {
+	if kv, ok := bc.cache[key]; ok {
 
 		// update 
-		// from double-link-list
-		bc.ll.MoveToFront(ele)
 		// resize
-		kv := ele.Value.(*entry)
 		bc.usedBytes += int64(value.Len() - kv.value.Len())
 		// value
 		kv.value = value
+		kv.ttl = ttl
+		kv.refreshing = false
 	} else {
-		// linklist
-		ele := bc.ll.PushFront(&entry{key: key, value: value})
 		// map
-		bc.cache[key] = ele
+		bc.cache[key] = &entry{key: key, value: value, ttl: ttl, owner: bc}
 		// resize
 		bc.usedBytes += int64(len(key) + value.Len())
 	}
+	bc.policy.Add(key, int64(len(key)+value.Len()))
 	// drop cache
 	for bc.maxBytes != 0 && bc.maxBytes < bc.usedBytes {
-		bc.RemoveOldest()
+		if !bc.evictOne() {
+			break
+		}
 	}
+	bc.updateSize()
 }
 
 func (bc *baseCache) Add(key string, value Value) {
@@ -145,38 +272,61 @@ func (bc *baseCache) Add(key string, value Value) {
 }
 
 func (bc *baseCache) Len() int {
-	return bc.ll.Len()
+	return len(bc.cache)
 }
 
-func (bc *baseCache) removeElement(ele *list.Element) {
-	// from double-link-list
-	bc.ll.Remove(ele)
+// removeEntry removes kv from the cache and from the eviction policy
+func (bc *baseCache) removeEntry(kv *entry) {
+	kv.owner = nil
+	bc.policy.Remove(kv.key)
 	// from map
-	kv := ele.Value.(*entry)
 	delete(bc.cache, kv.key)
 	// delete from expires
 	delete(bc.expires, kv.key)
 	// resize
 	bc.usedBytes -= int64(len(kv.key) + kv.value.Len())
+	bc.updateSize()
 }
 
-func (bc *baseCache) Remove(key string) {
-	if ele, ok := bc.cache[key]; ok {
-		bc.removeElement(ele)
-		kv := ele.Value.(*entry)
-		if bc.OnEvicted != nil {
-			bc.OnEvicted(kv.key, kv.value)
-		}
+// Remove removes the key, reports whether it was present
+func (bc *baseCache) Remove(key string) bool {
+	kv, ok := bc.cache[key]
+	if !ok {
+		return false
+	}
+	bc.removeEntry(kv)
+	if bc.OnEvicted != nil {
+		bc.OnEvicted(kv.key, kv.value)
 	}
+	return true
+}
+
+// CleanExpired removes the entries expired more than the grace time ago
+func (bc *baseCache) CleanExpired() {
+	bc.cleanExpired()
 }
 
 // remove expire
 func (bc *baseCache)  cleanExpired() {
 	now := time.Now()
 	for key, exp := range bc.expires {
-		if now.After(exp) { // remove
-			log.Println("basecache.go: Auto remove expired cache!")
-			bc.Remove(key)
+		if now.After(exp.Add(bc.grace)) { // remove
+			bc.logger.Debug("remove expired", "key", key)
+			bc.expire(key)
 		}
 	}
+}
+
+// expire removes an expired key
+func (bc *baseCache) expire(key string) {
+	if _, ok := bc.cache[key]; ok {
+		bc.stats.expirations.Add(1)
+	}
+	bc.Remove(key)
+}
+
+// updateSize publishes the size of the cache to the stats
+func (bc *baseCache) updateSize() {
+	bc.stats.bytes.Store(bc.usedBytes)
+	bc.stats.items.Store(int64(len(bc.cache)))
 }
\ No newline at end of file
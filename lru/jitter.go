@@ -0,0 +1,58 @@
+package lru
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// JitterPolicy decides how the expire time of an entry is spread
+type JitterPolicy int
+
+const (
+	// NoJitter keeps the expire time as is
+	NoJitter JitterPolicy = iota
+	// UniformJitter picks the expire time uniformly in [ttl*(1-Percent), ttl*(1+Percent)]
+	UniformJitter
+	// ExponentialJitter extends the expire time by an exponentially distributed
+	// amount with mean ttl*Percent, capped at ttl. Entries never expire earlier.
+	ExponentialJitter
+)
+
+// Jitter spreads the expire time of entries added together, so that they
+// don't all expire at the same time (cache avalanche)
+type Jitter struct {
+	Policy  JitterPolicy
+	Percent float64 // 0 <= Percent < 1
+}
+
+// Validate reports whether j can be used
+func (j Jitter) Validate() error {
+	switch j.Policy {
+	case NoJitter, UniformJitter, ExponentialJitter:
+	default:
+		return fmt.Errorf("unknown jitter policy %d", j.Policy)
+	}
+	if j.Percent < 0 || j.Percent >= 1 {
+		return fmt.Errorf("jitter percent must be in [0, 1), got %v", j.Percent)
+	}
+	return nil
+}
+
+// Apply returns ttl with jitter, ttl <= 0 is returned as is
+func (j Jitter) Apply(ttl time.Duration) time.Duration {
+	if ttl <= 0 || j.Percent <= 0 {
+		return ttl
+	}
+	switch j.Policy {
+	case UniformJitter:
+		return time.Duration(float64(ttl) * (1 + j.Percent*(2*rand.Float64()-1)))
+	case ExponentialJitter:
+		extra := rand.ExpFloat64() * j.Percent
+		if extra > 1 {
+			extra = 1
+		}
+		return ttl + time.Duration(float64(ttl)*extra)
+	}
+	return ttl
+}
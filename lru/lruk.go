@@ -1,6 +1,12 @@
 // 实现lruk
 package lru
 
+import (
+	"my_groupcache/logger"
+	"sync/atomic"
+	"time"
+)
+
 
 type Cache struct {
 	// history
@@ -9,16 +15,22 @@ type Cache struct {
 	// cache
 	cache *baseCache
 
-	// maxBytes
+	// maxBytes，两层共用
 	maxBytes int
 
 	// k
 	k int
+
+	// 晋升次数
+	promotions atomic.Int64
 }
 
+// NewCache creates an LRU-K cache, history and the hot tier together hold at
+// most maxBytes. 0 maxBytes means no limit.
 func NewCache(k int, maxBytes int, OnEvicted func(string, Value)) *Cache{
-	history := newBaseCache(int64(maxBytes), OnEvicted)
-	cache := newBaseCache(int64(maxBytes), OnEvicted)
+	// 每层不限制大小，由 fit 统一淘汰
+	history := newBaseCache(0, OnEvicted)
+	cache := newBaseCache(0, OnEvicted)
 	return &Cache{
 		history: history,
 		cache: cache,
@@ -29,64 +41,169 @@ func NewCache(k int, maxBytes int, OnEvicted func(string, Value)) *Cache{
 
 // Get
 func (c *Cache) Get(key string) (value Value, ok bool) {
+	value, stale, ok := c.GetStale(key)
+	if stale {
+		return nil, false
+	}
+	return value, ok
+}
+
+// GetStale is like Get, but also returns entries that expired less than
+// the grace time ago, stale reports whether the entry has expired
+func (c *Cache) GetStale(key string) (value Value, stale bool, ok bool) {
 	// from cache
-	if value, ok := c.cache.Get(key); ok {
-		return value, ok
+	if value, stale, ok := c.cache.GetStale(key); ok {
+		return value, stale, ok
 	}
 
 	// from history
-	if value, ok := c.history.Get(key); ok {
-		// visit += 1
-		ele := c.history.cache[key]
-		kv := ele.Value.(*entry)
+	if value, stale, ok := c.history.GetStale(key); ok {
+		kv := c.history.cache[key]
 		// visit +=1
 		kv.visit += 1
 
 		if kv.visit >= c.k {
 			// add to cache
-			c.cache.Add(kv.key, kv.value)
-			c.history.Remove(kv.key)
-		} else {
-			c.history.ll.MoveToFront(ele)
+			c.promote(kv)
 		}
-		return value, ok
+		return value, stale, ok
 	}
 	return
 }
 
+// Peek looks up both tiers without counting the access, see baseCache.Peek
+func (c *Cache) Peek(key string) (h Handle, stale bool, ok bool) {
+	if h, stale, ok := c.cache.Peek(key); ok {
+		return h, stale, ok
+	}
+	return c.history.Peek(key)
+}
+
+// Touch applies an access of an entry returned by Peek, an entry of history
+// is promoted once it has been accessed k times
+func (c *Cache) Touch(h Handle) {
+	switch kv := h.e; {
+	case kv == nil:
+	case kv.owner == c.cache:
+		c.cache.touch(kv)
+	case kv.owner == c.history:
+		c.history.touch(kv)
+		kv.visit += 1
+		if kv.visit >= c.k {
+			c.promote(kv)
+		}
+	}
+}
+
+// SetExpireTime sets the default expire time used by Add, 0 means never expire
+func (c *Cache) SetExpireTime(expireTime time.Duration) {
+	c.history.SetExpireTime(expireTime)
+	c.cache.SetExpireTime(expireTime)
+}
+
+// SetRefreshAhead calls fn when an entry of the hot tier is accessed with less
+// than fraction of its ttl left. fn reports whether the refresh was accepted,
+// it is not called again for the entry until the entry is updated.
+// fn is called with the cache locked and must not block.
+func (c *Cache) SetRefreshAhead(fraction float64, fn func(key string) bool) {
+	c.cache.SetRefreshAhead(fraction, fn)
+}
+
+// SetGrace keeps expired entries for grace, so that they can still be read by GetStale
+func (c *Cache) SetGrace(grace time.Duration) {
+	c.history.grace = grace
+	c.cache.grace = grace
+}
+
+// SetJitter sets the jitter applied to the expire time of added entries
+func (c *Cache) SetJitter(jitter Jitter) {
+	c.history.jitter = jitter
+	c.cache.jitter = jitter
+}
+
+// SetLogger sets the logger of both tiers, nil discards the logs
+func (c *Cache) SetLogger(l logger.Logger) {
+	c.history.logger = logger.With(l, "tier", "history")
+	c.cache.logger = logger.With(l, "tier", "hot")
+}
+
 func (c *Cache) Add(key string, value Value) {
+	c.AddWithExpire(key, value, c.cache.expireTime)
+}
+
+// AddWithExpire adds a value that expires after expire, 0 means never expire
+func (c *Cache) AddWithExpire(key string, value Value, expire time.Duration) {
+	defer c.fit()
 	// in cache
 	if _, ok := c.cache.cache[key]; ok {
-		c.cache.Add(key, value)
+		c.cache.AddWithExpire(key, value, expire)
 		return
 	}
 
 	// in history
-	if ele, ok := c.history.cache[key]; ok {
-		kv := ele.Value.(*entry)
-		kv.value = value
+	if kv, ok := c.history.cache[key]; ok {
 		kv.visit += 1
 		// in cache
 		if kv.visit >= c.k {
-			c.history.Remove(key)
-			c.cache.Add(key, value)
+			// 晋升不是淘汰，不触发回调
+			c.history.removeEntry(kv)
+			c.cache.AddWithExpire(key, value, expire)
+			c.promotions.Add(1)
 		} else {
-			c.history.Add(key, value)
+			c.history.AddWithExpire(key, value, expire)
 		}
 		return
 	} else {
-		c.history.Add(key, value)
-		ele := c.history.cache[key]
-		kv := ele.Value.(*entry)
-		kv.visit += 1
+		c.history.AddWithExpire(key, value, expire)
+		if kv, ok := c.history.cache[key]; ok {
+			kv.visit += 1
+		}
 		return
 	}
 
 }
 
-func (c *Cache) Remove(key string) {
+// fit evicts entries until both tiers together hold at most maxBytes. History
+// is evicted first while it holds more than half of maxBytes, so that keys
+// seen only once can't flush the hot tier, otherwise the hot tier is evicted.
+func (c *Cache) fit() {
+	limit := int64(c.maxBytes)
+	for limit > 0 && c.history.usedBytes+c.cache.usedBytes > limit {
+		tier := c.cache
+		if c.history.usedBytes > limit/2 || len(c.cache.cache) == 0 {
+			tier = c.history
+		}
+		if !tier.evictOne() {
+			return
+		}
+	}
+}
+
+// promote moves an entry from history to cache, keeping its expire time
+func (c *Cache) promote(kv *entry) {
+	deadline := c.history.expires[kv.key]
+	c.history.removeEntry(kv)
+	c.cache.addWithDeadline(kv.key, kv.value, deadline, kv.ttl)
+	c.promotions.Add(1)
+}
+
+// Stats returns the stats of the cache, it can be called without holding the
+// lock that guards the other methods
+func (c *Cache) Stats() Stats {
+	return Stats{
+		History:    c.history.stats.snapshot(),
+		Hot:        c.cache.stats.snapshot(),
+		Promotions: c.promotions.Load(),
+	}
+}
+
+// Remove removes the key from both history and cache, reports whether it was present
+func (c *Cache) Remove(key string) bool {
+	_, inHistory := c.history.cache[key]
+	_, inCache := c.cache.cache[key]
 	c.history.Remove(key)
 	c.cache.Remove(key)
+	return inHistory || inCache
 }
 
 func (c *Cache) RemoveOldest() {
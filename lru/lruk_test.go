@@ -102,11 +102,11 @@ func TestLRUKEviction(t *testing.T) {
 
 	c.Add("Z", String("ZZZZZZZZZZ")) // 10 bytes, total = 30
 
-	// Add large entry to force eviction
-	c.Add("BIG", String("01234567890123456789")) // 20 bytes
+	// Add large entry to force eviction from history
+	c.Add("BIG", String("0123456789012345678901234567890123456789")) // 40 bytes
 
-	if len(evicted) == 0 {
-		t.Error("Expected eviction to happen")
+	if len(evicted) == 0 || evicted[0] != "Y" {
+		t.Errorf("Expected Y to be evicted, got %v", evicted)
 	}
 }
 
@@ -123,3 +123,218 @@ func TestLRUKExpire(t *testing.T) {
 		t.Error("Expected key 'temp' to expire and be removed")
 	}
 }
+
+func TestLRUKAddWithExpire(t *testing.T) {
+	c := NewCache(2, 100, nil)
+	c.AddWithExpire("short", String("1"), 300*time.Millisecond)
+	c.AddWithExpire("forever", String("2"), 0)
+
+	// promote, expire time should be kept
+	c.Get("short")
+	if _, ok := c.cache.cache["short"]; !ok {
+		t.Fatal("expect 'short' promoted to cache")
+	}
+	time.Sleep(400 * time.Millisecond)
+	if _, ok := c.Get("short"); ok {
+		t.Error("expect 'short' to keep its ttl after promotion")
+	}
+	if _, ok := c.Get("forever"); !ok {
+		t.Error("expect 'forever' never expire")
+	}
+
+	if !c.Remove("forever") {
+		t.Error("expect Remove to report 'forever' was present")
+	}
+	if c.Remove("forever") {
+		t.Error("expect Remove to report 'forever' missing")
+	}
+}
+
+func TestLRUKPromoteNotEvicted(t *testing.T) {
+	evicted := 0
+	c := NewCache(2, 100, func(string, Value) { evicted++ })
+	c.Add("a", String("1"))
+	c.Get("a") // promote by Get
+	c.Add("b", String("2"))
+	c.Add("b", String("2")) // promote by Add
+	if _, ok := c.cache.cache["b"]; !ok {
+		t.Fatal("expect 'b' promoted to cache")
+	}
+	if evicted != 0 {
+		t.Fatalf("promotion should not call OnEvicted, called %d times", evicted)
+	}
+
+	c.SetExpireTime(0)
+	c.Add("c", String("3"))
+	if _, ok := c.history.expires["c"]; ok {
+		t.Fatal("expect 'c' never expire")
+	}
+}
+
+func TestJitter(t *testing.T) {
+	if err := (Jitter{Policy: UniformJitter, Percent: 1}).Validate(); err == nil {
+		t.Error("expect percent 1 to be invalid")
+	}
+	if err := (Jitter{Policy: JitterPolicy(42)}).Validate(); err == nil {
+		t.Error("expect unknown policy to be invalid")
+	}
+
+	ttl := time.Second
+	uniform := Jitter{Policy: UniformJitter, Percent: 0.2}
+	exponential := Jitter{Policy: ExponentialJitter, Percent: 0.2}
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 1000; i++ {
+		d := uniform.Apply(ttl)
+		if d < 800*time.Millisecond || d > 1200*time.Millisecond {
+			t.Fatalf("uniform jitter out of range: %v", d)
+		}
+		seen[d] = true
+		if d := exponential.Apply(ttl); d < ttl || d > 2*ttl {
+			t.Fatalf("exponential jitter out of range: %v", d)
+		}
+	}
+	if len(seen) < 100 {
+		t.Fatalf("expect expire times to be spread, got %d distinct values", len(seen))
+	}
+	if d := (Jitter{}).Apply(ttl); d != ttl {
+		t.Fatalf("expect no jitter by default, got %v", d)
+	}
+	if d := uniform.Apply(0); d != 0 {
+		t.Fatalf("expect 0 (never expire) to be kept, got %v", d)
+	}
+}
+
+func TestLRUKJitter(t *testing.T) {
+	c := NewCache(2, 1<<10, nil)
+	c.SetJitter(Jitter{Policy: UniformJitter, Percent: 0.5})
+	deadlines := make(map[time.Time]bool)
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("k%d", i)
+		c.AddWithExpire(key, String("v"), time.Minute)
+		deadlines[c.history.expires[key]] = true
+	}
+	if len(deadlines) < 40 {
+		t.Fatalf("expect keys added together to expire at different times, got %d", len(deadlines))
+	}
+}
+
+func TestLRUKGrace(t *testing.T) {
+	c := NewCache(2, 100, nil)
+	c.SetGrace(200 * time.Millisecond)
+	c.AddWithExpire("a", String("1"), 50*time.Millisecond)
+	c.AddWithExpire("b", String("2"), 50*time.Millisecond)
+	c.Get("b") // promote
+
+	time.Sleep(100 * time.Millisecond)
+	for _, key := range []string{"a", "b"} {
+		if _, ok := c.Get(key); ok {
+			t.Fatalf("expect Get to miss expired %s", key)
+		}
+		if v, stale, ok := c.GetStale(key); !ok || !stale || v == nil {
+			t.Fatalf("expect %s to be served stale during grace", key)
+		}
+	}
+	c.CleanExpired()
+	if _, _, ok := c.GetStale("a"); !ok {
+		t.Fatal("expect CleanExpired to keep entries in grace")
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	c.CleanExpired()
+	if _, _, ok := c.GetStale("a"); ok {
+		t.Fatal("expect 'a' removed after grace")
+	}
+	if _, _, ok := c.GetStale("b"); ok {
+		t.Fatal("expect 'b' removed after grace")
+	}
+}
+
+func TestLRUKRefreshAhead(t *testing.T) {
+	var refreshed []string
+	accept := false
+	c := NewCache(2, 100, nil)
+	c.SetRefreshAhead(0.5, func(key string) bool {
+		refreshed = append(refreshed, key)
+		return accept
+	})
+	c.AddWithExpire("hot", String("1"), 200*time.Millisecond)
+	c.AddWithExpire("cold", String("2"), 200*time.Millisecond)
+	c.Get("hot") // promote
+
+	c.Get("hot")
+	if len(refreshed) != 0 {
+		t.Fatalf("expect no refresh early in the ttl, got %v", refreshed)
+	}
+
+	time.Sleep(120 * time.Millisecond)
+	c.Get("cold") // history 中的数据不提前刷新
+	c.Get("hot")  // 未接受，下次访问重试
+	accept = true
+	c.Get("hot")
+	c.Get("hot") // 已接受，不再重复触发
+	if len(refreshed) != 2 || refreshed[0] != "hot" || refreshed[1] != "hot" {
+		t.Fatalf("expect hot to be refreshed until accepted, got %v", refreshed)
+	}
+
+	// 更新后重新计时
+	c.AddWithExpire("hot", String("3"), 200*time.Millisecond)
+	c.Get("hot")
+	if len(refreshed) != 2 {
+		t.Fatalf("expect no refresh after the update, got %v", refreshed)
+	}
+}
+
+func TestLRUKStats(t *testing.T) {
+	c := NewCache(2, 40, nil)
+	c.AddWithExpire("a", String("1234567890"), 50*time.Millisecond) // 11 bytes
+	c.Add("b", String("1234567890"))
+	c.Get("b") // promote
+	s := c.Stats()
+	if s.Promotions != 1 || s.History.Items != 1 || s.Hot.Items != 1 || s.Bytes() != 22 || s.Items() != 2 {
+		t.Fatalf("unexpected stats after promotion: %+v", s)
+	}
+
+	c.Add("c", String("1234567890"))
+	c.Add("d", String("1234567890")) // 两层超过 40 字节，history 超过一半，淘汰 a
+	if s := c.Stats(); s.History.Evictions != 1 || s.History.Items != 2 {
+		t.Fatalf("expect one eviction from history: %+v", s)
+	}
+
+	c.AddWithExpire("e", String("1"), 10*time.Millisecond)
+	c.Get("e") // promote
+	time.Sleep(20 * time.Millisecond)
+	c.CleanExpired()
+	if s := c.Stats(); s.Hot.Expirations != 1 || s.Hot.Items != 1 {
+		t.Fatalf("expect one expiration from hot tier: %+v", s)
+	}
+
+	c.Remove("b")
+	if s := c.Stats(); s.Hot.Items != 0 || s.Hot.Bytes != 0 || s.Hot.Evictions != 0 {
+		t.Fatalf("expect Remove not to count as eviction: %+v", s)
+	}
+}
+
+func TestLRUKSharedBudget(t *testing.T) {
+	c := NewCache(2, 100, nil)
+	// 热点层可以使用 history 没用到的空间
+	for i := 0; i < 8; i++ {
+		key := fmt.Sprintf("hot%d", i)
+		c.AddWithExpire(key, String("123456"), 0) // 10 bytes
+		c.Get(key)
+	}
+	if s := c.Stats(); s.Hot.Items != 8 || s.Bytes() != 80 {
+		t.Fatalf("hot tier should hold 80 bytes: %+v", s)
+	}
+
+	// 只访问一次的 key 只在 history 内部淘汰，直到热点层让出一半空间
+	for i := 0; i < 100; i++ {
+		c.AddWithExpire(fmt.Sprintf("scan%02d", i), String("12345"), 0) // 11 bytes
+		if s := c.Stats(); s.Bytes() > 100 {
+			t.Fatalf("over budget: %+v", s)
+		}
+	}
+	s := c.Stats()
+	if s.Hot.Items < 4 || s.History.Bytes > 50+11 {
+		t.Fatalf("history should not take more than half of the budget from the hot tier: %+v", s)
+	}
+}
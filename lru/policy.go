@@ -0,0 +1,161 @@
+package lru
+
+import "container/list"
+
+// EvictionPolicy decides which entry of a store is evicted when it is full.
+// It only tracks keys, the store keeps the values, the expire times and the
+// byte accounting. It is called with the store locked.
+type EvictionPolicy interface {
+	// Add is called when key is inserted or its value is replaced,
+	// size is len(key) + value.Len()
+	Add(key string, size int64)
+	// Access is called on a hit of key
+	Access(key string)
+	// Remove is called when key is removed or expires, it is a no-op for keys
+	// the policy does not hold
+	Remove(key string)
+	// Evict forgets the key that should be evicted next and returns it,
+	// ok is false if the policy holds no key
+	Evict() (key string, ok bool)
+}
+
+// lruPolicy evicts the least recently used key
+type lruPolicy struct {
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+// NewLRUPolicy evicts the least recently used key
+func NewLRUPolicy() EvictionPolicy {
+	return &lruPolicy{ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (p *lruPolicy) Add(key string, size int64) {
+	if ele, ok := p.items[key]; ok {
+		p.ll.MoveToFront(ele)
+		return
+	}
+	p.items[key] = p.ll.PushFront(key)
+}
+
+func (p *lruPolicy) Access(key string) {
+	if ele, ok := p.items[key]; ok {
+		p.ll.MoveToFront(ele)
+	}
+}
+
+func (p *lruPolicy) Remove(key string) {
+	if ele, ok := p.items[key]; ok {
+		p.ll.Remove(ele)
+		delete(p.items, key)
+	}
+}
+
+func (p *lruPolicy) Evict() (string, bool) {
+	ele := p.ll.Back()
+	if ele == nil {
+		return "", false
+	}
+	key := p.ll.Remove(ele).(string)
+	delete(p.items, key)
+	return key, true
+}
+
+// fifoPolicy evicts the key inserted first, accesses and updates don't change the order
+type fifoPolicy struct {
+	lruPolicy
+}
+
+// NewFIFOPolicy evicts the key that was inserted first
+func NewFIFOPolicy() EvictionPolicy {
+	return &fifoPolicy{lruPolicy{ll: list.New(), items: make(map[string]*list.Element)}}
+}
+
+func (p *fifoPolicy) Add(key string, size int64) {
+	if _, ok := p.items[key]; !ok {
+		p.items[key] = p.ll.PushFront(key)
+	}
+}
+
+func (p *fifoPolicy) Access(key string) {}
+
+// lfuPolicy evicts the least frequently used key, the least recently used
+// one among keys with the same frequency. All operations are O(1).
+type lfuPolicy struct {
+	freqs *list.List // *lfuFreq，频率从小到大
+	items map[string]*list.Element
+}
+
+type lfuFreq struct {
+	freq  int
+	items *list.List // *lfuItem，最近访问的在前
+}
+
+type lfuItem struct {
+	key  string
+	freq *list.Element // 所在的 lfuFreq
+}
+
+// NewLFUPolicy evicts the least frequently used key
+func NewLFUPolicy() EvictionPolicy {
+	return &lfuPolicy{freqs: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (p *lfuPolicy) Add(key string, size int64) {
+	if _, ok := p.items[key]; ok {
+		p.Access(key)
+		return
+	}
+	front := p.freqs.Front()
+	if front == nil || front.Value.(*lfuFreq).freq != 1 {
+		front = p.freqs.PushFront(&lfuFreq{freq: 1, items: list.New()})
+	}
+	p.items[key] = front.Value.(*lfuFreq).items.PushFront(&lfuItem{key: key, freq: front})
+}
+
+func (p *lfuPolicy) Access(key string) {
+	ele, ok := p.items[key]
+	if !ok {
+		return
+	}
+	item := ele.Value.(*lfuItem)
+	cur := item.freq
+	f := cur.Value.(*lfuFreq)
+	next := cur.Next()
+	if next == nil || next.Value.(*lfuFreq).freq != f.freq+1 {
+		next = p.freqs.InsertAfter(&lfuFreq{freq: f.freq + 1, items: list.New()}, cur)
+	}
+	f.items.Remove(ele)
+	if f.items.Len() == 0 {
+		p.freqs.Remove(cur)
+	}
+	item.freq = next
+	p.items[key] = next.Value.(*lfuFreq).items.PushFront(item)
+}
+
+func (p *lfuPolicy) Remove(key string) {
+	if ele, ok := p.items[key]; ok {
+		p.unlink(ele)
+	}
+}
+
+func (p *lfuPolicy) Evict() (string, bool) {
+	front := p.freqs.Front()
+	if front == nil {
+		return "", false
+	}
+	ele := front.Value.(*lfuFreq).items.Back()
+	key := ele.Value.(*lfuItem).key
+	p.unlink(ele)
+	return key, true
+}
+
+func (p *lfuPolicy) unlink(ele *list.Element) {
+	item := ele.Value.(*lfuItem)
+	f := item.freq.Value.(*lfuFreq)
+	f.items.Remove(ele)
+	if f.items.Len() == 0 {
+		p.freqs.Remove(item.freq)
+	}
+	delete(p.items, item.key)
+}
@@ -0,0 +1,81 @@
+package lru
+
+import "sync/atomic"
+
+// Handle is an entry of a Store returned by Peek, its access is applied later
+// with Touch
+type Handle struct {
+	e *entry
+}
+
+// Value returns the value of the entry
+func (h Handle) Value() Value {
+	return h.e.value
+}
+
+// ReadBuffer is a lossy ring buffer of the entries hit by readers. Readers
+// record hits without a lock and the owner of the Store applies them in
+// batches with Drain, so hits don't have to take the write lock to update the
+// eviction policy. Hits are dropped when the buffer is full or another reader
+// wins the slot, which only makes the recency and frequency a little less
+// precise.
+type ReadBuffer struct {
+	slots []atomic.Pointer[entry]
+	mask  uint64
+	// 下一个写入的位置
+	head atomic.Uint64
+	// 下一个待处理的位置，只有 Drain 修改
+	tail atomic.Uint64
+	// 丢弃的访问记录数
+	dropped atomic.Int64
+}
+
+// NewReadBuffer creates a buffer of size slots rounded up to a power of two
+func NewReadBuffer(size int) *ReadBuffer {
+	n := 1
+	for n < size {
+		n <<= 1
+	}
+	return &ReadBuffer{slots: make([]atomic.Pointer[entry], n), mask: uint64(n - 1)}
+}
+
+// Record adds a hit of h, it reports whether the buffer is at least half full
+// and should be drained. It does not allocate and is safe for concurrent use.
+func (b *ReadBuffer) Record(h Handle) bool {
+	// 先读 tail，保证 head >= tail
+	tail := b.tail.Load()
+	head := b.head.Load()
+	size := head - tail
+	if size >= uint64(len(b.slots)) {
+		b.dropped.Add(1)
+		return true
+	}
+	if !b.head.CompareAndSwap(head, head+1) {
+		// 其他读者抢到了这个位置，丢弃而不是重试
+		b.dropped.Add(1)
+		return false
+	}
+	b.slots[head&b.mask].Store(h.e)
+	return size+1 >= uint64(len(b.slots))/2
+}
+
+// Drain applies the recorded hits to s in order with Touch. It must be called
+// with the lock that guards s and not concurrently with itself.
+func (b *ReadBuffer) Drain(s Store) {
+	tail := b.tail.Load()
+	head := b.head.Load()
+	for ; tail != head; tail++ {
+		e := b.slots[tail&b.mask].Swap(nil)
+		if e == nil {
+			// 读者占了位置但还没写入，下次再处理
+			break
+		}
+		s.Touch(Handle{e})
+	}
+	b.tail.Store(tail)
+}
+
+// Dropped returns the number of hits that were not recorded
+func (b *ReadBuffer) Dropped() int64 {
+	return b.dropped.Load()
+}
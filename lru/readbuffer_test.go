@@ -0,0 +1,130 @@
+package lru
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// touchRecorder records the entries touched by ReadBuffer.Drain
+type touchRecorder struct {
+	Store
+	keys []string
+}
+
+func (r *touchRecorder) Touch(h Handle) {
+	r.keys = append(r.keys, h.e.key)
+	r.Store.Touch(h)
+}
+
+func peek(t *testing.T, s Store, key string) Handle {
+	t.Helper()
+	h, _, ok := s.Peek(key)
+	if !ok {
+		t.Fatalf("Peek(%s) missed", key)
+	}
+	return h
+}
+
+func TestReadBuffer(t *testing.T) {
+	s := &touchRecorder{Store: New(PolicyLRU, 0, 0, nil)}
+	for i := 0; i < 5; i++ {
+		s.AddWithExpire(fmt.Sprintf("k%d", i), String("v"), 0)
+	}
+	b := NewReadBuffer(3) // 4 slots
+	for i, want := range []bool{false, true, true, true} {
+		if full := b.Record(peek(t, s, fmt.Sprintf("k%d", i))); full != want {
+			t.Fatalf("Record(k%d) = %v, want %v", i, full, want)
+		}
+	}
+	// 满了以后丢弃
+	b.Record(peek(t, s, "k4"))
+	if b.Dropped() != 1 {
+		t.Fatalf("Dropped() = %d, want 1", b.Dropped())
+	}
+	b.Drain(s)
+	if len(s.keys) != 4 || s.keys[0] != "k0" || s.keys[3] != "k3" {
+		t.Fatalf("drained %v, want k0..k3 in order", s.keys)
+	}
+
+	// 环绕后继续可用，已经删除的数据不再生效
+	s.keys = nil
+	b.Record(peek(t, s, "k4"))
+	h := peek(t, s, "k0")
+	b.Record(h)
+	s.Remove("k0")
+	b.Drain(s)
+	if len(s.keys) != 2 || s.keys[0] != "k4" {
+		t.Fatalf("drained %v, want [k4 k0]", s.keys)
+	}
+}
+
+func TestReadBufferConcurrent(t *testing.T) {
+	s := &touchRecorder{Store: New(PolicyLRU, 0, 0, nil)}
+	s.AddWithExpire("key", String("v"), 0)
+	h := peek(t, s, "key")
+	b := NewReadBuffer(16)
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	drain := func() {
+		mu.Lock()
+		b.Drain(s)
+		mu.Unlock()
+	}
+	const readers, reads = 8, 1000
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < reads; j++ {
+				if b.Record(h) {
+					drain()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	drain()
+	// 每次访问要么被应用，要么被丢弃
+	if got := len(s.keys) + int(b.Dropped()); got != readers*reads {
+		t.Fatalf("drained %d + dropped %d = %d, want %d", len(s.keys), b.Dropped(), got, readers*reads)
+	}
+}
+
+func TestReadBufferRecordAllocs(t *testing.T) {
+	s := New(PolicyLRU, 0, 0, nil)
+	s.AddWithExpire("key", String("v"), 0)
+	h := peek(t, s, "key")
+	b := NewReadBuffer(64)
+	allocs := testing.AllocsPerRun(100, func() {
+		if b.Record(h) {
+			b.Drain(s)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("Record allocates %v times per hit", allocs)
+	}
+}
+
+func TestTouch(t *testing.T) {
+	forEachStore(t, func(t *testing.T, newStore func(int64, func(string, Value)) Store) {
+		s := newStore(0, nil)
+		s.AddWithExpire("Tom", String("630"), 0)
+		// 和 Get 一样计数，LRU-K 访问 k 次后晋升
+		s.Touch(peek(t, s, "Tom"))
+		if v, ok := s.Get("Tom"); !ok || v.(String) != "630" {
+			t.Fatalf("Get(Tom) = %v, %v", v, ok)
+		}
+		h := peek(t, s, "Tom")
+		if h.Value().(String) != "630" {
+			t.Fatalf("Value() = %v", h.Value())
+		}
+		s.Remove("Tom")
+		s.Touch(h) // 已经删除，不生效
+		if st := s.Stats(); st.Items() != 0 {
+			t.Fatalf("Touch of a removed entry changed the store: %+v", st)
+		}
+	})
+}
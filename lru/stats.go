@@ -0,0 +1,45 @@
+package lru
+
+import "sync/atomic"
+
+// TierStats are the stats of one tier of the cache
+type TierStats struct {
+	Bytes       int64 // 当前占用的字节数
+	Items       int64 // 当前的数据条数
+	Evictions   int64 // 因为容量不足被淘汰的次数
+	Expirations int64 // 过期被清理的次数
+}
+
+// Stats are the stats of a Cache
+type Stats struct {
+	History    TierStats // 访问次数不足 k 的数据
+	Hot        TierStats // 热点层
+	Promotions int64     // 从 history 晋升到热点层的次数
+}
+
+// Bytes returns the bytes used by both tiers
+func (s Stats) Bytes() int64 {
+	return s.History.Bytes + s.Hot.Bytes
+}
+
+// Items returns the number of items in both tiers
+func (s Stats) Items() int64 {
+	return s.History.Items + s.Hot.Items
+}
+
+// tierCounters are updated with the cache locked and read without the lock
+type tierCounters struct {
+	bytes       atomic.Int64
+	items       atomic.Int64
+	evictions   atomic.Int64
+	expirations atomic.Int64
+}
+
+func (t *tierCounters) snapshot() TierStats {
+	return TierStats{
+		Bytes:       t.bytes.Load(),
+		Items:       t.items.Load(),
+		Evictions:   t.evictions.Load(),
+		Expirations: t.expirations.Load(),
+	}
+}
@@ -0,0 +1,113 @@
+package lru
+
+import (
+	"my_groupcache/logger"
+	"time"
+)
+
+// Store is a cache bounded by bytes with expiring entries. Implementations
+// are not safe for concurrent use, except for Stats and for Peek running
+// alongside other Peeks.
+type Store interface {
+	// Get returns the value of key if it has not expired
+	Get(key string) (value Value, ok bool)
+	// GetStale is like Get, but also returns entries that expired less than
+	// the grace time ago, stale reports whether the entry has expired
+	GetStale(key string) (value Value, stale bool, ok bool)
+	// Peek is like GetStale, but does not count as an access and never changes
+	// the store, so concurrent Peeks only need a read lock
+	Peek(key string) (h Handle, stale bool, ok bool)
+	// Touch applies an access of an entry returned by Peek, like a Get of its
+	// key would. It does nothing if the entry was removed since.
+	Touch(h Handle)
+	// Add adds a value that expires after the default expire time
+	Add(key string, value Value)
+	// AddWithExpire adds a value that expires after expire, 0 means never expire
+	AddWithExpire(key string, value Value, expire time.Duration)
+	// Remove removes the key, reports whether it was present
+	Remove(key string) bool
+	// CleanExpired removes the entries expired more than the grace time ago
+	CleanExpired()
+	// Stats can be called without holding the lock that guards the other methods
+	Stats() Stats
+
+	SetExpireTime(expireTime time.Duration)
+	SetJitter(jitter Jitter)
+	SetGrace(grace time.Duration)
+	SetRefreshAhead(fraction float64, fn func(key string) bool)
+	SetLogger(l logger.Logger)
+}
+
+var (
+	_ Store = (*Cache)(nil)
+	_ Store = (*baseCache)(nil)
+)
+
+// PolicyType selects the eviction policy of a Store created by New
+type PolicyType int
+
+const (
+	// PolicyLRUK keeps keys accessed less than k times in a separate history
+	// tier, see NewCache. It is the default.
+	PolicyLRUK PolicyType = iota
+	// PolicyLRU evicts the least recently used key
+	PolicyLRU
+	// PolicyFIFO evicts the key inserted first
+	PolicyFIFO
+	// PolicyLFU evicts the least frequently used key
+	PolicyLFU
+	// PolicyTinyLFU admits keys into the main LRU only if they are estimated
+	// to be used more often than the key they replace, see NewTinyLFUPolicy
+	PolicyTinyLFU
+	// PolicyARC balances recency and frequency with an adaptive target size,
+	// see NewARCPolicy
+	PolicyARC
+)
+
+var policyNames = map[PolicyType]string{
+	PolicyLRUK:    "lru-k",
+	PolicyLRU:     "lru",
+	PolicyFIFO:    "fifo",
+	PolicyLFU:     "lfu",
+	PolicyTinyLFU: "w-tinylfu",
+	PolicyARC:     "arc",
+}
+
+func (p PolicyType) String() string {
+	if name, ok := policyNames[p]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Valid reports whether p is a known policy
+func (p PolicyType) Valid() bool {
+	_, ok := policyNames[p]
+	return ok
+}
+
+// New creates a Store of maxBytes evicting with policy, k is only used by PolicyLRUK.
+// It panics if policy is not Valid.
+func New(policy PolicyType, k int, maxBytes int64, onEvicted func(string, Value)) Store {
+	switch policy {
+	case PolicyLRUK:
+		return NewCache(k, int(maxBytes), onEvicted)
+	case PolicyLRU:
+		return NewPolicyStore(maxBytes, NewLRUPolicy(), onEvicted)
+	case PolicyFIFO:
+		return NewPolicyStore(maxBytes, NewFIFOPolicy(), onEvicted)
+	case PolicyLFU:
+		return NewPolicyStore(maxBytes, NewLFUPolicy(), onEvicted)
+	case PolicyTinyLFU:
+		return NewPolicyStore(maxBytes, NewTinyLFUPolicy(maxBytes), onEvicted)
+	case PolicyARC:
+		return NewPolicyStore(maxBytes, NewARCPolicy(maxBytes), onEvicted)
+	}
+	panic("lru: unknown policy " + policy.String())
+}
+
+// NewPolicyStore creates a Store of maxBytes evicting with policy,
+// 0 maxBytes means no limit
+func NewPolicyStore(maxBytes int64, policy EvictionPolicy, onEvicted func(string, Value)) Store {
+	return newPolicyCache(maxBytes, policy, onEvicted)
+}
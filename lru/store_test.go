@@ -0,0 +1,320 @@
+package lru
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// stores are checked by the conformance tests, a new policy only has to be added here
+var stores = []struct {
+	name string
+	new  func(maxBytes int64, onEvicted func(string, Value)) Store
+}{
+	{"lru-k", func(maxBytes int64, onEvicted func(string, Value)) Store {
+		return New(PolicyLRUK, 2, maxBytes, onEvicted)
+	}},
+	{"lru", func(maxBytes int64, onEvicted func(string, Value)) Store {
+		return New(PolicyLRU, 0, maxBytes, onEvicted)
+	}},
+	{"fifo", func(maxBytes int64, onEvicted func(string, Value)) Store {
+		return New(PolicyFIFO, 0, maxBytes, onEvicted)
+	}},
+	{"lfu", func(maxBytes int64, onEvicted func(string, Value)) Store {
+		return New(PolicyLFU, 0, maxBytes, onEvicted)
+	}},
+	{"w-tinylfu", func(maxBytes int64, onEvicted func(string, Value)) Store {
+		return New(PolicyTinyLFU, 0, maxBytes, onEvicted)
+	}},
+	{"arc", func(maxBytes int64, onEvicted func(string, Value)) Store {
+		return New(PolicyARC, 0, maxBytes, onEvicted)
+	}},
+}
+
+// evictRecorder records the calls to OnEvicted
+type evictRecorder struct {
+	keys []string
+}
+
+func (r *evictRecorder) onEvicted(key string, value Value) {
+	r.keys = append(r.keys, key)
+}
+
+func forEachStore(t *testing.T, test func(t *testing.T, newStore func(maxBytes int64, onEvicted func(string, Value)) Store)) {
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			test(t, s.new)
+		})
+	}
+}
+
+func TestStoreGetAdd(t *testing.T) {
+	forEachStore(t, func(t *testing.T, newStore func(int64, func(string, Value)) Store) {
+		r := &evictRecorder{}
+		s := newStore(0, r.onEvicted)
+		s.AddWithExpire("Tom", String("630"), 0)
+		if v, ok := s.Get("Tom"); !ok || v.(String) != "630" {
+			t.Fatalf("Get(Tom) = %v, %v", v, ok)
+		}
+		if _, ok := s.Get("Jack"); ok {
+			t.Fatal("Get(Jack) should miss")
+		}
+
+		// 更新不是淘汰
+		s.AddWithExpire("Tom", String("6300"), 0)
+		if v, ok := s.Get("Tom"); !ok || v.(String) != "6300" {
+			t.Fatalf("Get(Tom) after update = %v, %v", v, ok)
+		}
+		if len(r.keys) != 0 {
+			t.Fatalf("update called OnEvicted for %v", r.keys)
+		}
+		if st := s.Stats(); st.Bytes() != int64(len("Tom")+len("6300")) || st.Items() != 1 {
+			t.Fatalf("Stats() = %+v", st)
+		}
+	})
+}
+
+func TestStoreByteAccounting(t *testing.T) {
+	forEachStore(t, func(t *testing.T, newStore func(int64, func(string, Value)) Store) {
+		r := &evictRecorder{}
+		const maxBytes = 100
+		s := newStore(maxBytes, r.onEvicted)
+		for i := 0; i < 50; i++ {
+			key := fmt.Sprintf("key%02d", i)
+			s.AddWithExpire(key, String("value"), 0)
+			s.Get(key)
+			st := s.Stats()
+			if st.Bytes() > maxBytes {
+				t.Fatalf("over budget after %s: %+v", key, st)
+			}
+		}
+		st := s.Stats()
+		if len(r.keys) == 0 || st.History.Evictions+st.Hot.Evictions != int64(len(r.keys)) {
+			t.Fatalf("evicted %d keys, stats %+v", len(r.keys), st)
+		}
+		// 剩余的数据加上被淘汰的数据等于写入的数据
+		if st.Items()+int64(len(r.keys)) != 50 || st.Bytes() != st.Items()*int64(len("key00")+len("value")) {
+			t.Fatalf("Stats() = %+v, evicted %d", st, len(r.keys))
+		}
+		for _, key := range r.keys {
+			if _, ok := s.Get(key); ok {
+				t.Fatalf("evicted key %s still cached", key)
+			}
+		}
+	})
+}
+
+func TestStoreOversizedEntry(t *testing.T) {
+	forEachStore(t, func(t *testing.T, newStore func(int64, func(string, Value)) Store) {
+		r := &evictRecorder{}
+		s := newStore(10, r.onEvicted)
+		s.AddWithExpire("big", String("0123456789"), 0)
+		if _, ok := s.Get("big"); ok {
+			t.Fatal("entry larger than maxBytes should not be kept")
+		}
+		if len(r.keys) != 1 || r.keys[0] != "big" || s.Stats().Bytes() != 0 {
+			t.Fatalf("evicted %v, stats %+v", r.keys, s.Stats())
+		}
+	})
+}
+
+func TestStoreRemove(t *testing.T) {
+	forEachStore(t, func(t *testing.T, newStore func(int64, func(string, Value)) Store) {
+		r := &evictRecorder{}
+		s := newStore(0, r.onEvicted)
+		s.AddWithExpire("Tom", String("630"), 0)
+		s.AddWithExpire("Jack", String("589"), 0)
+		if !s.Remove("Tom") || s.Remove("Tom") {
+			t.Fatal("Remove should report whether the key was present")
+		}
+		if _, ok := s.Get("Tom"); ok {
+			t.Fatal("Tom should be removed")
+		}
+		if len(r.keys) != 1 || r.keys[0] != "Tom" {
+			t.Fatalf("OnEvicted called for %v", r.keys)
+		}
+		if st := s.Stats(); st.Bytes() != int64(len("Jack")+len("589")) || st.History.Evictions+st.Hot.Evictions != 0 {
+			t.Fatalf("Stats() = %+v", st)
+		}
+	})
+}
+
+func TestStoreExpire(t *testing.T) {
+	forEachStore(t, func(t *testing.T, newStore func(int64, func(string, Value)) Store) {
+		r := &evictRecorder{}
+		s := newStore(0, r.onEvicted)
+		s.SetExpireTime(30 * time.Millisecond)
+		s.Add("default", String("v"))
+		s.AddWithExpire("short", String("v"), 30*time.Millisecond)
+		s.AddWithExpire("forever", String("v"), 0)
+		s.AddWithExpire("long", String("v"), time.Hour)
+
+		time.Sleep(50 * time.Millisecond)
+		for _, key := range []string{"default", "short"} {
+			if _, ok := s.Get(key); ok {
+				t.Fatalf("%s should have expired", key)
+			}
+		}
+		for _, key := range []string{"forever", "long"} {
+			if _, ok := s.Get(key); !ok {
+				t.Fatalf("%s should not expire", key)
+			}
+		}
+		if len(r.keys) != 2 {
+			t.Fatalf("expired entries should call OnEvicted, got %v", r.keys)
+		}
+		if st := s.Stats(); st.Items() != 2 || st.History.Expirations+st.Hot.Expirations != 2 {
+			t.Fatalf("Stats() = %+v", st)
+		}
+	})
+}
+
+func TestStoreCleanExpired(t *testing.T) {
+	forEachStore(t, func(t *testing.T, newStore func(int64, func(string, Value)) Store) {
+		r := &evictRecorder{}
+		s := newStore(0, r.onEvicted)
+		s.AddWithExpire("short", String("v"), 20*time.Millisecond)
+		s.AddWithExpire("forever", String("v"), 0)
+		time.Sleep(30 * time.Millisecond)
+		s.CleanExpired()
+		if st := s.Stats(); st.Items() != 1 || st.Bytes() != int64(len("forever")+1) {
+			t.Fatalf("Stats() = %+v", st)
+		}
+		if len(r.keys) != 1 || r.keys[0] != "short" {
+			t.Fatalf("OnEvicted called for %v", r.keys)
+		}
+	})
+}
+
+func TestStoreGrace(t *testing.T) {
+	forEachStore(t, func(t *testing.T, newStore func(int64, func(string, Value)) Store) {
+		s := newStore(0, nil)
+		s.SetGrace(time.Hour)
+		s.AddWithExpire("Tom", String("630"), 20*time.Millisecond)
+		time.Sleep(30 * time.Millisecond)
+		if _, ok := s.Get("Tom"); ok {
+			t.Fatal("Get should not return a stale entry")
+		}
+		if v, stale, ok := s.GetStale("Tom"); !ok || !stale || v.(String) != "630" {
+			t.Fatalf("GetStale(Tom) = %v, %v, %v", v, stale, ok)
+		}
+		s.CleanExpired()
+		if _, _, ok := s.GetStale("Tom"); !ok {
+			t.Fatal("CleanExpired should keep entries in their grace window")
+		}
+	})
+}
+
+func TestStoreRefreshAhead(t *testing.T) {
+	forEachStore(t, func(t *testing.T, newStore func(int64, func(string, Value)) Store) {
+		var refreshed []string
+		s := newStore(0, nil)
+		s.SetRefreshAhead(0.5, func(key string) bool {
+			refreshed = append(refreshed, key)
+			return true
+		})
+		s.AddWithExpire("Tom", String("630"), 100*time.Millisecond)
+		s.Get("Tom") // LRU-K 晋升到热点层
+		time.Sleep(60 * time.Millisecond)
+		s.Get("Tom")
+		s.Get("Tom")
+		if len(refreshed) != 1 {
+			t.Fatalf("refreshed %v, want one refresh of Tom", refreshed)
+		}
+	})
+}
+
+func TestStorePeek(t *testing.T) {
+	forEachStore(t, func(t *testing.T, newStore func(int64, func(string, Value)) Store) {
+		r := &evictRecorder{}
+		s := newStore(0, r.onEvicted)
+		s.SetGrace(time.Hour)
+		s.SetRefreshAhead(1, func(key string) bool {
+			t.Fatalf("Peek refreshed %s", key)
+			return false
+		})
+		s.AddWithExpire("Tom", String("630"), time.Hour)
+		s.AddWithExpire("Jack", String("589"), 20*time.Millisecond)
+		before := s.Stats()
+		if h, stale, ok := s.Peek("Tom"); !ok || stale || h.Value().(String) != "630" {
+			t.Fatalf("Peek(Tom) = %v, %v", stale, ok)
+		}
+		if _, _, ok := s.Peek("Sanji"); ok {
+			t.Fatal("Peek(Sanji) should miss")
+		}
+		time.Sleep(30 * time.Millisecond)
+		if h, stale, ok := s.Peek("Jack"); !ok || !stale || h.Value().(String) != "589" {
+			t.Fatalf("Peek(Jack) = %v, %v", stale, ok)
+		}
+		// 不晋升、不过期，也不回调
+		if after := s.Stats(); after != before || len(r.keys) != 0 {
+			t.Fatalf("Peek changed the store: %+v, was %+v, evicted %v", after, before, r.keys)
+		}
+	})
+}
+
+func TestPeekKeepsOrder(t *testing.T) {
+	for _, policy := range []PolicyType{PolicyLRU, PolicyLFU} {
+		t.Run(policy.String(), func(t *testing.T) {
+			r := &evictRecorder{}
+			s := New(policy, 0, 4, r.onEvicted)
+			s.AddWithExpire("a", String("1"), 0)
+			s.AddWithExpire("b", String("1"), 0)
+			s.Peek("a")
+			s.AddWithExpire("c", String("1"), 0)
+			if len(r.keys) != 1 || r.keys[0] != "a" {
+				t.Fatalf("evicted %v, Peek should not protect a", r.keys)
+			}
+		})
+	}
+}
+
+func TestPolicyOrder(t *testing.T) {
+	// 容量刚好放下三条数据，a 被访问过，随后写入 d
+	tests := []struct {
+		policy  PolicyType
+		evicted string
+	}{
+		{PolicyLRU, "b"},
+		{PolicyFIFO, "a"},
+		{PolicyLFU, "b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			r := &evictRecorder{}
+			s := New(tt.policy, 0, 6, r.onEvicted)
+			for _, key := range []string{"a", "b", "c"} {
+				s.AddWithExpire(key, String("1"), 0)
+			}
+			s.Get("a")
+			s.Get("c")
+			s.Get("c")
+			s.AddWithExpire("d", String("1"), 0)
+			if len(r.keys) != 1 || r.keys[0] != tt.evicted {
+				t.Fatalf("evicted %v, want %s", r.keys, tt.evicted)
+			}
+		})
+	}
+}
+
+func TestLFUPolicyTies(t *testing.T) {
+	p := NewLFUPolicy()
+	for _, key := range []string{"a", "b", "c"} {
+		p.Add(key, 1)
+	}
+	p.Access("a")
+	p.Access("b")
+	p.Access("a")
+	p.Remove("c")
+	// a: 3, b: 2
+	p.Add("d", 1)
+	want := []string{"d", "b", "a"}
+	for _, w := range want {
+		if key, ok := p.Evict(); !ok || key != w {
+			t.Fatalf("Evict() = %s, %v, want %s", key, ok, w)
+		}
+	}
+	if _, ok := p.Evict(); ok {
+		t.Fatal("empty policy should not evict")
+	}
+}
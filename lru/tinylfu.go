@@ -0,0 +1,286 @@
+package lru
+
+import (
+	"container/list"
+	"hash/fnv"
+)
+
+const (
+	// 窗口占总容量的比例
+	tinyLFUWindowRatio = 0.01
+	// 受保护段占主区的比例
+	tinyLFUProtectedRatio = 0.8
+	// 估算 sketch 宽度时假设的平均条目大小
+	tinyLFUEntrySize = 64
+	tinyLFUMinWidth  = 64
+	// 计数器上限，4 bit
+	sketchMaxCount = 15
+	// 每个 key 在 sketch 中对应的计数器个数
+	sketchDepth = 4
+	// 计数次数达到 sketchResetFactor*width 后所有计数器减半
+	sketchResetFactor = 10
+)
+
+// countMinSketch estimates the access frequency of keys with saturating 4-bit
+// counters. Counters are halved every sketchResetFactor*width increments, so
+// that keys that were popular long ago fade out.
+type countMinSketch struct {
+	rows      [sketchDepth][]uint8
+	mask      uint32
+	additions int
+	resetAt   int
+}
+
+func newCountMinSketch(width int) *countMinSketch {
+	w := tinyLFUMinWidth
+	for w < width {
+		w <<= 1
+	}
+	s := &countMinSketch{mask: uint32(w - 1), resetAt: sketchResetFactor * w}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, w)
+	}
+	return s
+}
+
+func (s *countMinSketch) width() int {
+	return int(s.mask) + 1
+}
+
+// widen doubles the width of the sketch and keeps the estimate of every key.
+// The counter of a key in the new rows is at its old index or that index plus
+// the old width, both start with the old counter.
+func (s *countMinSketch) widen() {
+	w := s.width()
+	for i := range s.rows {
+		row := make([]uint8, 2*w)
+		copy(row, s.rows[i])
+		copy(row[w:], s.rows[i])
+		s.rows[i] = row
+	}
+	s.mask = uint32(2*w - 1)
+	s.resetAt = sketchResetFactor * 2 * w
+}
+
+// indexes derives the counter of each row from one 64-bit hash
+func (s *countMinSketch) indexes(key string) [sketchDepth]uint32 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	h1, h2 := uint32(sum), uint32(sum>>32)|1
+	var idx [sketchDepth]uint32
+	for i := range idx {
+		idx[i] = (h1 + uint32(i)*h2) & s.mask
+	}
+	return idx
+}
+
+func (s *countMinSketch) increment(key string) {
+	idx := s.indexes(key)
+	// 只增加最小的计数器（conservative update），减少高估
+	min := s.estimateIdx(idx)
+	if min >= sketchMaxCount {
+		return
+	}
+	for i, j := range idx {
+		if s.rows[i][j] == min {
+			s.rows[i][j]++
+		}
+	}
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.age()
+	}
+}
+
+func (s *countMinSketch) estimate(key string) uint8 {
+	return s.estimateIdx(s.indexes(key))
+}
+
+func (s *countMinSketch) estimateIdx(idx [sketchDepth]uint32) uint8 {
+	min := uint8(sketchMaxCount)
+	for i, j := range idx {
+		if c := s.rows[i][j]; c < min {
+			min = c
+		}
+	}
+	return min
+}
+
+// age halves all counters
+func (s *countMinSketch) age() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
+
+// segments of W-TinyLFU
+const (
+	segWindow = iota
+	segProbation
+	segProtected
+)
+
+type tinyLFUItem struct {
+	key  string
+	size int64
+	seg  int
+}
+
+// tinyLFUPolicy is W-TinyLFU: new keys enter a small window LRU, keys leaving
+// the window compete with the victim of the segmented main LRU and the one
+// the sketch estimates to be less frequent is evicted. Keys hit in the
+// probation segment of the main LRU move to the protected segment.
+type tinyLFUPolicy struct {
+	sketch *countMinSketch
+	items  map[string]*list.Element
+	segs   [3]*list.List // 最近访问的在前
+	bytes  [3]int64
+
+	windowMax    int64
+	mainMax      int64
+	protectedMax int64 // 0 表示不限制
+}
+
+// NewTinyLFUPolicy creates a W-TinyLFU policy for a store of maxBytes,
+// 0 maxBytes means no limit
+func NewTinyLFUPolicy(maxBytes int64) EvictionPolicy {
+	p := &tinyLFUPolicy{
+		sketch: newCountMinSketch(int(maxBytes / tinyLFUEntrySize)),
+		items:  make(map[string]*list.Element),
+	}
+	for i := range p.segs {
+		p.segs[i] = list.New()
+	}
+	if maxBytes > 0 {
+		p.windowMax = int64(float64(maxBytes) * tinyLFUWindowRatio)
+		if p.windowMax < 1 {
+			p.windowMax = 1
+		}
+		p.mainMax = maxBytes - p.windowMax
+		p.protectedMax = int64(float64(p.mainMax) * tinyLFUProtectedRatio)
+	}
+	return p
+}
+
+func (p *tinyLFUPolicy) Add(key string, size int64) {
+	p.record(key)
+	if ele, ok := p.items[key]; ok {
+		item := ele.Value.(*tinyLFUItem)
+		p.bytes[item.seg] += size - item.size
+		item.size = size
+		p.hit(ele)
+		return
+	}
+	p.items[key] = p.segs[segWindow].PushFront(&tinyLFUItem{key: key, size: size, seg: segWindow})
+	p.bytes[segWindow] += size
+	p.drainWindow()
+}
+
+func (p *tinyLFUPolicy) Access(key string) {
+	p.record(key)
+	if ele, ok := p.items[key]; ok {
+		p.hit(ele)
+	}
+}
+
+func (p *tinyLFUPolicy) Remove(key string) {
+	if ele, ok := p.items[key]; ok {
+		p.unlink(ele)
+	}
+}
+
+// Evict lets the oldest key of an oversized window compete with the victim
+// of the main LRU, otherwise evicts the victim of the main LRU
+func (p *tinyLFUPolicy) Evict() (string, bool) {
+	var candidate *list.Element
+	if p.bytes[segWindow] > p.windowMax {
+		candidate = p.segs[segWindow].Back()
+	}
+	victim := p.mainVictim()
+	switch {
+	case candidate == nil && victim == nil:
+		candidate = p.segs[segWindow].Back()
+		if candidate == nil {
+			return "", false
+		}
+	case candidate == nil:
+		return p.evict(victim), true
+	case victim == nil:
+	default:
+		c, v := candidate.Value.(*tinyLFUItem), victim.Value.(*tinyLFUItem)
+		// 频率相同时拒绝新 key，保护主区中已经证明过的数据
+		if p.sketch.estimate(c.key) > p.sketch.estimate(v.key) {
+			key := p.evict(victim)
+			p.drainWindow()
+			return key, true
+		}
+	}
+	return p.evict(candidate), true
+}
+
+// record counts an access of key in the sketch, the sketch is widened without
+// losing the counts when the policy holds more keys than it has counters
+func (p *tinyLFUPolicy) record(key string) {
+	if len(p.items) > p.sketch.width() {
+		p.sketch.widen()
+	}
+	p.sketch.increment(key)
+}
+
+// hit moves a key to the front of its segment, promoting probation keys
+func (p *tinyLFUPolicy) hit(ele *list.Element) {
+	item := ele.Value.(*tinyLFUItem)
+	if item.seg != segProbation {
+		p.segs[item.seg].MoveToFront(ele)
+		return
+	}
+	p.move(ele, segProtected)
+	// 受保护段满了，把最旧的降级回试用段
+	for p.protectedMax > 0 && p.bytes[segProtected] > p.protectedMax && p.segs[segProtected].Len() > 1 {
+		p.move(p.segs[segProtected].Back(), segProbation)
+	}
+}
+
+// drainWindow moves the oldest keys of an oversized window to the probation
+// segment while the main LRU has room for them
+func (p *tinyLFUPolicy) drainWindow() {
+	for p.bytes[segWindow] > p.windowMax {
+		ele := p.segs[segWindow].Back()
+		item := ele.Value.(*tinyLFUItem)
+		if p.mainMax > 0 && p.bytes[segProbation]+p.bytes[segProtected]+item.size > p.mainMax {
+			return
+		}
+		p.move(ele, segProbation)
+	}
+}
+
+// mainVictim is the oldest key of probation, or of protected if probation is empty
+func (p *tinyLFUPolicy) mainVictim() *list.Element {
+	if ele := p.segs[segProbation].Back(); ele != nil {
+		return ele
+	}
+	return p.segs[segProtected].Back()
+}
+
+func (p *tinyLFUPolicy) move(ele *list.Element, seg int) {
+	item := p.unlink(ele)
+	item.seg = seg
+	p.items[item.key] = p.segs[seg].PushFront(item)
+	p.bytes[seg] += item.size
+}
+
+func (p *tinyLFUPolicy) evict(ele *list.Element) string {
+	return p.unlink(ele).key
+}
+
+func (p *tinyLFUPolicy) unlink(ele *list.Element) *tinyLFUItem {
+	item := ele.Value.(*tinyLFUItem)
+	p.segs[item.seg].Remove(ele)
+	p.bytes[item.seg] -= item.size
+	delete(p.items, item.key)
+	return item
+}
@@ -0,0 +1,210 @@
+package lru
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestCountMinSketch(t *testing.T) {
+	s := newCountMinSketch(64)
+	for i := 0; i < 5; i++ {
+		s.increment("hot")
+	}
+	s.increment("cold")
+	if got := s.estimate("hot"); got != 5 {
+		t.Fatalf("estimate(hot) = %d, want 5", got)
+	}
+	if got := s.estimate("cold"); got != 1 {
+		t.Fatalf("estimate(cold) = %d, want 1", got)
+	}
+	if got := s.estimate("missing"); got > 1 {
+		t.Fatalf("estimate(missing) = %d", got)
+	}
+
+	// 计数器饱和
+	for i := 0; i < 100; i++ {
+		s.increment("hot")
+	}
+	if got := s.estimate("hot"); got != sketchMaxCount {
+		t.Fatalf("estimate(hot) = %d, want %d", got, sketchMaxCount)
+	}
+}
+
+func TestCountMinSketchAging(t *testing.T) {
+	s := newCountMinSketch(64)
+	for i := 0; i < 8; i++ {
+		s.increment("old")
+	}
+	// 其他 key 的访问累计到 resetAt 后计数减半
+	for i := 0; i < 10*s.resetAt; i++ {
+		before := s.additions
+		s.increment(fmt.Sprintf("k%d", i))
+		if s.additions < before {
+			break
+		}
+	}
+	if got := s.estimate("old"); got != 4 {
+		t.Fatalf("estimate(old) after aging = %d, want 4", got)
+	}
+}
+
+func TestCountMinSketchWiden(t *testing.T) {
+	s := newCountMinSketch(64)
+	want := make(map[string]uint8)
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("k%d", i)
+		for j := 0; j < i%7; j++ {
+			s.increment(key)
+		}
+	}
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("k%d", i)
+		want[key] = s.estimate(key)
+	}
+	s.widen()
+	if s.width() != 128 || s.resetAt != sketchResetFactor*128 {
+		t.Fatalf("width = %d, resetAt = %d", s.width(), s.resetAt)
+	}
+	// 加宽后估计值不变，包括已经不在缓存中的 key
+	for key, n := range want {
+		if got := s.estimate(key); got != n {
+			t.Fatalf("estimate(%s) = %d after widen, want %d", key, got, n)
+		}
+	}
+}
+
+func TestTinyLFUScanResistance(t *testing.T) {
+	hot := make([]string, 50)
+	for i := range hot {
+		hot[i] = fmt.Sprintf("hot%02d", i) // 10 bytes with the value
+	}
+	// 热点 key 访问多次后，一次性扫描大量只访问一次的 key
+	survivors := func(policy PolicyType) int {
+		s := New(policy, 0, 100*10, nil)
+		for round := 0; round < 5; round++ {
+			for _, key := range hot {
+				if _, ok := s.Get(key); !ok {
+					s.AddWithExpire(key, String("abcde"), 0)
+				}
+			}
+		}
+		for i := 0; i < 1000; i++ {
+			s.AddWithExpire(fmt.Sprintf("scan%d", i), String("abc"), 0)
+		}
+		n := 0
+		for _, key := range hot {
+			if _, ok := s.Get(key); ok {
+				n++
+			}
+		}
+		return n
+	}
+	if n := survivors(PolicyLRU); n != 0 {
+		t.Fatalf("lru kept %d hot keys, the scan should flush them all", n)
+	}
+	// 窗口中的 key 还没有进入主区，可能被扫描淘汰
+	if n := survivors(PolicyTinyLFU); n < len(hot)*9/10 {
+		t.Fatalf("w-tinylfu kept %d of %d hot keys after a scan", n, len(hot))
+	}
+}
+
+// workload is a trace of keys, the value of every key has the same size
+type workload struct {
+	name  string
+	trace []string
+}
+
+const (
+	traceKeys      = 10000
+	traceLen       = 200000
+	traceValueSize = 16
+	traceCacheSize = 500 // 缓存能放下的条目数
+)
+
+// zipfTrace draws keys from a zipf distribution with a fixed seed
+func zipfTrace(r *rand.Rand, n int) []string {
+	z := rand.NewZipf(r, 1.1, 1, traceKeys-1)
+	trace := make([]string, n)
+	for i := range trace {
+		trace[i] = fmt.Sprintf("z%05d", z.Uint64())
+	}
+	return trace
+}
+
+func workloads() []workload {
+	r := rand.New(rand.NewPCG(1, 2))
+	zipf := zipfTrace(r, traceLen)
+
+	// 每 10000 次访问插入一次 2000 个新 key 的扫描
+	var scan []string
+	for i, key := range zipfTrace(r, traceLen) {
+		if i%10000 == 0 {
+			for j := 0; j < 2000; j++ {
+				scan = append(scan, fmt.Sprintf("s%03d-%04d", i/10000, j))
+			}
+		}
+		scan = append(scan, key)
+	}
+	return []workload{{"zipf", zipf}, {"zipf+scan", scan}}
+}
+
+// replay plays trace against s, adding keys on a miss, and returns the hit ratio
+func replay(s Store, trace []string) float64 {
+	value := String(make([]byte, traceValueSize))
+	hits := 0
+	for _, key := range trace {
+		if _, ok := s.Get(key); ok {
+			hits++
+			continue
+		}
+		s.AddWithExpire(key, value, 0)
+	}
+	return float64(hits) / float64(len(trace))
+}
+
+// replayStores are the policies compared on the traces, each holds about the same bytes
+func replayStores() []struct {
+	name string
+	new  func() Store
+} {
+	maxBytes := int64(traceCacheSize * (len("z00000") + traceValueSize))
+	return []struct {
+		name string
+		new  func() Store
+	}{
+		{"lru-k", func() Store { return New(PolicyLRUK, 2, maxBytes, nil) }},
+		{"w-tinylfu", func() Store { return New(PolicyTinyLFU, 0, maxBytes, nil) }},
+		{"arc", func() Store { return New(PolicyARC, 0, maxBytes, nil) }},
+	}
+}
+
+func TestTinyLFUHitRatio(t *testing.T) {
+	for _, w := range workloads() {
+		ratios := make(map[string]float64)
+		for _, s := range replayStores() {
+			ratios[s.name] = replay(s.new(), w.trace)
+		}
+		t.Logf("%s: lru-k %.4f, w-tinylfu %.4f, arc %.4f", w.name, ratios["lru-k"], ratios["w-tinylfu"], ratios["arc"])
+		if ratios["w-tinylfu"] < ratios["lru-k"] {
+			t.Errorf("%s: w-tinylfu hit ratio %.4f is below lru-k %.4f", w.name, ratios["w-tinylfu"], ratios["lru-k"])
+		}
+	}
+}
+
+// BenchmarkTraceReplay replays the same traces against LRU-K, W-TinyLFU and ARC,
+// the hit ratio is reported as the hit-ratio metric
+func BenchmarkTraceReplay(b *testing.B) {
+	for _, w := range workloads() {
+		for _, s := range replayStores() {
+			b.Run(w.name+"/"+s.name, func(b *testing.B) {
+				var ratio float64
+				for i := 0; i < b.N; i++ {
+					ratio = replay(s.new(), w.trace)
+				}
+				b.ReportMetric(ratio, "hit-ratio")
+				b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*len(w.trace)), "ns/access")
+			})
+		}
+	}
+}
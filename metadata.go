@@ -0,0 +1,21 @@
+package mygroupcache
+
+import (
+	"context"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// WithMetadata attaches request-scoped key/value pairs (trace id, tenant ...)
+// to ctx. They travel to the owning peer as gRPC metadata.
+func WithMetadata(ctx context.Context, kv ...string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+// MetadataFromContext returns the pairs attached with WithMetadata, no matter
+// whether the Getter runs on this node or on a remote peer.
+func MetadataFromContext(ctx context.Context) metadata.MD {
+	in, _ := metadata.FromIncomingContext(ctx)
+	out, _ := metadata.FromOutgoingContext(ctx)
+	return metadata.Join(in, out)
+}
@@ -0,0 +1,190 @@
+// prometheus text exposition format, without depending on the prometheus client
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// DefBuckets are the default latency buckets in seconds
+var DefBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5}
+
+// Histogram counts observations in fixed buckets, safe for concurrent use
+type Histogram struct {
+	bounds []float64
+	counts []atomic.Uint64 // 每个桶的计数（非累计），最后一个是 +Inf
+	sum    atomic.Uint64   // float64 bits
+	count  atomic.Uint64
+}
+
+// NewHistogram creates a histogram with the given upper bounds
+func NewHistogram(bounds []float64) *Histogram {
+	bounds = append([]float64(nil), bounds...)
+	sort.Float64s(bounds)
+	return &Histogram{bounds: bounds, counts: make([]atomic.Uint64, len(bounds)+1)}
+}
+
+// Observe records v
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v)
+	h.counts[i].Add(1)
+	for {
+		old := h.sum.Load()
+		if h.sum.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			break
+		}
+	}
+	h.count.Add(1)
+}
+
+// HistogramSnapshot is a point in time copy of a Histogram
+type HistogramSnapshot struct {
+	Bounds []float64
+	Counts []uint64 // cumulative count of each bound, without +Inf
+	Sum    float64
+	Count  uint64
+}
+
+// Snapshot returns the current values of h
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	s := HistogramSnapshot{Bounds: h.bounds, Counts: make([]uint64, len(h.bounds))}
+	var cum uint64
+	for i := range h.bounds {
+		cum += h.counts[i].Load()
+		s.Counts[i] = cum
+	}
+	s.Sum = math.Float64frombits(h.sum.Load())
+	s.Count = h.count.Load()
+	return s
+}
+
+// Label is a name="value" pair of a sample
+type Label struct {
+	Name, Value string
+}
+
+type sample struct {
+	suffix string
+	labels []Label
+	value  float64
+}
+
+type family struct {
+	name, typ, help string
+	samples         []sample
+}
+
+// Writer collects samples and writes them grouped by metric family,
+// families are written in the order they were first used
+type Writer struct {
+	families []*family
+	index    map[string]*family
+}
+
+// NewWriter creates an empty Writer
+func NewWriter() *Writer {
+	return &Writer{index: make(map[string]*family)}
+}
+
+func (w *Writer) family(name, typ, help string) *family {
+	f, ok := w.index[name]
+	if !ok {
+		f = &family{name: name, typ: typ, help: help}
+		w.index[name] = f
+		w.families = append(w.families, f)
+	}
+	return f
+}
+
+// Counter adds a counter sample
+func (w *Writer) Counter(name, help string, value float64, labels ...Label) {
+	f := w.family(name, "counter", help)
+	f.samples = append(f.samples, sample{labels: labels, value: value})
+}
+
+// Gauge adds a gauge sample
+func (w *Writer) Gauge(name, help string, value float64, labels ...Label) {
+	f := w.family(name, "gauge", help)
+	f.samples = append(f.samples, sample{labels: labels, value: value})
+}
+
+// Histogram adds the buckets, sum and count of a histogram
+func (w *Writer) Histogram(name, help string, s HistogramSnapshot, labels ...Label) {
+	f := w.family(name, "histogram", help)
+	for i, bound := range s.Bounds {
+		f.samples = append(f.samples, sample{
+			suffix: "_bucket",
+			labels: withLabel(labels, Label{"le", formatFloat(bound)}),
+			value:  float64(s.Counts[i]),
+		})
+	}
+	f.samples = append(f.samples,
+		sample{suffix: "_bucket", labels: withLabel(labels, Label{"le", "+Inf"}), value: float64(s.Count)},
+		sample{suffix: "_sum", labels: labels, value: s.Sum},
+		sample{suffix: "_count", labels: labels, value: float64(s.Count)},
+	)
+}
+
+// WriteTo writes the collected samples in the text exposition format
+func (w *Writer) WriteTo(out io.Writer) (int64, error) {
+	var b strings.Builder
+	for _, f := range w.families {
+		fmt.Fprintf(&b, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+		fmt.Fprintf(&b, "# TYPE %s %s\n", f.name, f.typ)
+		for _, s := range f.samples {
+			b.WriteString(f.name)
+			b.WriteString(s.suffix)
+			writeLabels(&b, s.labels)
+			b.WriteByte(' ')
+			b.WriteString(formatFloat(s.value))
+			b.WriteByte('\n')
+		}
+	}
+	n, err := io.WriteString(out, b.String())
+	return int64(n), err
+}
+
+func withLabel(labels []Label, l Label) []Label {
+	return append(append([]Label(nil), labels...), l)
+}
+
+func writeLabels(b *strings.Builder, labels []Label) {
+	if len(labels) == 0 {
+		return
+	}
+	b.WriteByte('{')
+	for i, l := range labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(l.Name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(l.Value))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func escapeHelp(s string) string { return helpEscaper.Replace(s) }
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram([]float64{1, 0.1, 0.5})
+	for _, v := range []float64{0.05, 0.1, 0.3, 0.7, 2} {
+		h.Observe(v)
+	}
+	s := h.Snapshot()
+	want := []uint64{2, 3, 4} // <=0.1, <=0.5, <=1
+	for i := range want {
+		if s.Counts[i] != want[i] {
+			t.Fatalf("Counts = %v, want %v", s.Counts, want)
+		}
+	}
+	if s.Count != 5 || s.Sum < 3.14 || s.Sum > 3.16 {
+		t.Fatalf("Count = %d, Sum = %v", s.Count, s.Sum)
+	}
+}
+
+func TestHistogramConcurrency(t *testing.T) {
+	h := NewHistogram(DefBuckets)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				h.Observe(0.001)
+			}
+		}()
+	}
+	wg.Wait()
+	if s := h.Snapshot(); s.Count != 8000 || s.Sum < 7.99 || s.Sum > 8.01 {
+		t.Fatalf("Count = %d, Sum = %v", s.Count, s.Sum)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	w := NewWriter()
+	w.Counter("cache_gets_total", "Gets.", 3, Label{"group", "scores"})
+	w.Gauge("cache_bytes", "Bytes in\ncache.", 10, Label{"group", `a"b\c`})
+	w.Counter("cache_gets_total", "Gets.", 4, Label{"group", "users"})
+	h := NewHistogram([]float64{0.1})
+	h.Observe(0.05)
+	h.Observe(1)
+	w.Histogram("latency_seconds", "Latency.", h.Snapshot(), Label{"peer", "p1"})
+
+	var b strings.Builder
+	if _, err := w.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP cache_gets_total Gets.
+# TYPE cache_gets_total counter
+cache_gets_total{group="scores"} 3
+cache_gets_total{group="users"} 4
+# HELP cache_bytes Bytes in\ncache.
+# TYPE cache_bytes gauge
+cache_bytes{group="a\"b\\c"} 10
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{peer="p1",le="0.1"} 1
+latency_seconds_bucket{peer="p1",le="+Inf"} 2
+latency_seconds_sum{peer="p1"} 1.05
+latency_seconds_count{peer="p1"} 2
+`
+	if b.String() != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
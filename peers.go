@@ -1,6 +1,7 @@
 package mygroupcache
 
 import (
+	"context"
 	pb "my_groupcache/cachepb"
 )
 
@@ -11,8 +12,16 @@ type PeerPicker interface {
 }
 
 // PeerGetter is the interface that must be implemented by a peer.
+// ctx carries the caller's deadline, cancellation and metadata to the peer.
 type PeerGetter interface {
-	Get(in *pb.Request, out *pb.Response) error
+	Get(ctx context.Context, in *pb.Request, out *pb.Response) error
+	Set(ctx context.Context, in *pb.SetRequest, out *pb.Response) error
+	Delete(ctx context.Context, in *pb.Request, out *pb.ResponseForDelete) error
+}
+
+// BloomFetcher is implemented by peers that can ship the bloom filter of a group
+type BloomFetcher interface {
+	GetBloomFilter(ctx context.Context, in *pb.BloomRequest, out *pb.BloomFilter) error
 }
 
 var portPicker PeerPicker
@@ -0,0 +1,38 @@
+package registry
+
+import "context"
+
+// EventType is the kind of a membership change
+type EventType int
+
+const (
+	// EventAdd means addr joined the service
+	EventAdd EventType = iota
+	// EventDelete means addr left the service
+	EventDelete
+)
+
+// Event is a membership change of a service
+type Event struct {
+	Type EventType
+	Addr string
+}
+
+// Registry is a service discovery backend
+type Registry interface {
+	// Register announces addr under service and keeps it alive until Deregister
+	Register(ctx context.Context, service, addr string) error
+	// Deregister removes addr from service
+	Deregister(ctx context.Context, service, addr string) error
+	// Resolve returns the addrs currently registered under service
+	Resolve(ctx context.Context, service string) ([]string, error)
+	// Watch first sends an EventAdd for every registered addr, then every change
+	// after that. The channel is closed once ctx is done.
+	Watch(ctx context.Context, service string) (<-chan Event, error)
+}
+
+var (
+	_ Registry = (*EtcdRegistry)(nil)
+	_ Registry = (*Static)(nil)
+	_ Registry = (*Memory)(nil)
+)
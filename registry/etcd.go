@@ -0,0 +1,322 @@
+package registry
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"my_groupcache/logger"
+	"strings"
+	"sync"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	defaultEtcdEndpoint    = "127.0.0.1:2379"
+	defaultEtcdDialTimeout = 5 * time.Second
+	defaultLeaseTTL        = 5 // 租约时间，单位秒
+	// 续约中断后重新注册的退避时间
+	minRegisterBackoff = 500 * time.Millisecond
+	maxRegisterBackoff = 10 * time.Second
+)
+
+// EtcdOptions configures the connection to etcd, zero fields take the defaults
+type EtcdOptions struct {
+	// Endpoints defaults to 127.0.0.1:2379
+	Endpoints []string
+	// DialTimeout defaults to 5s
+	DialTimeout time.Duration
+	// Username and Password enable etcd auth when Username is set
+	Username string
+	Password string
+	// TLS enables TLS to etcd when not nil
+	TLS *tls.Config
+	// LeaseTTL is the ttl in seconds of the registration lease, defaults to 5
+	LeaseTTL int64
+	// KeyPrefix is prepended to every key, e.g. "/prod/" gives /prod/groupcache/<addr>
+	KeyPrefix string
+	// Logger receives the logs of the registry, nil discards them
+	Logger logger.Logger
+}
+
+// clientConfig converts the options into a clientv3.Config
+func (o EtcdOptions) clientConfig() clientv3.Config {
+	config := clientv3.Config{
+		Endpoints:   o.Endpoints,
+		DialTimeout: o.DialTimeout,
+		Username:    o.Username,
+		Password:    o.Password,
+		TLS:         o.TLS,
+	}
+	if len(config.Endpoints) == 0 {
+		config.Endpoints = []string{defaultEtcdEndpoint}
+	}
+	if config.DialTimeout == 0 {
+		config.DialTimeout = defaultEtcdDialTimeout
+	}
+	return config
+}
+
+func (o EtcdOptions) leaseTTL() int64 {
+	if o.LeaseTTL <= 0 {
+		return defaultLeaseTTL
+	}
+	return o.LeaseTTL
+}
+
+// EtcdRegistry keeps services under <prefix><service>/<addr> keys bound to a lease
+type EtcdRegistry struct {
+	opts   EtcdOptions
+	logger logger.Logger
+
+	once sync.Once
+	cli  *clientv3.Client
+	err  error
+
+	mu     sync.Mutex
+	leases map[string]*etcdLease // key -> lease
+}
+
+type etcdLease struct {
+	id     clientv3.LeaseID   // 重新注册后会改变，由 EtcdRegistry.mu 保护
+	cancel context.CancelFunc // 停止续约
+}
+
+// NewEtcdRegistry creates a Registry backed by etcd, the client is created on first use
+func NewEtcdRegistry(opts EtcdOptions) *EtcdRegistry {
+	return &EtcdRegistry{
+		opts:   opts,
+		logger: logger.OrNop(opts.Logger),
+		leases: make(map[string]*etcdLease),
+	}
+}
+
+func (r *EtcdRegistry) client() (*clientv3.Client, error) {
+	r.once.Do(func() {
+		r.cli, r.err = clientv3.New(r.opts.clientConfig())
+		if r.err != nil {
+			r.err = fmt.Errorf("create etcd client failed: %v", r.err)
+		}
+	})
+	return r.cli, r.err
+}
+
+// servicePrefix is the prefix of all the keys of service
+func (r *EtcdRegistry) servicePrefix(service string) string {
+	return r.opts.KeyPrefix + service + "/"
+}
+
+func (r *EtcdRegistry) serviceKey(service, addr string) string {
+	return r.servicePrefix(service) + addr
+}
+
+// Register puts addr under a lease and keeps renewing it. If the lease is
+// lost, e.g. etcd was unreachable for longer than the lease ttl, addr is
+// registered again with backoff until Deregister or until ctx is canceled.
+// The deadline of ctx only applies to the first registration.
+func (r *EtcdRegistry) Register(ctx context.Context, service, addr string) error {
+	cli, err := r.client()
+	if err != nil {
+		return err
+	}
+	key := r.serviceKey(service, addr)
+	id, err := r.put(ctx, cli, key, addr)
+	if err != nil {
+		return err
+	}
+
+	// 续约不跟随 ctx 的 deadline，只在 ctx 被取消或 Deregister 时停止
+	keepCtx, cancelKeep := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		if ctx.Err() == context.Canceled {
+			cancelKeep()
+		}
+	})
+	cancel := func() {
+		stop()
+		cancelKeep()
+	}
+	ch, err := cli.KeepAlive(keepCtx, id)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("set keepalive failed: %v", err)
+	}
+
+	lease := &etcdLease{id: id, cancel: cancel}
+	r.mu.Lock()
+	if old, ok := r.leases[key]; ok {
+		old.cancel()
+	}
+	r.leases[key] = lease
+	r.mu.Unlock()
+	go r.keepAlive(keepCtx, cli, lease, key, addr, ch)
+
+	r.logger.Info("registered", "service", service, "addr", addr)
+	return nil
+}
+
+// put grants a lease and puts addr under key with it
+func (r *EtcdRegistry) put(ctx context.Context, cli *clientv3.Client, key, addr string) (clientv3.LeaseID, error) {
+	resp, err := cli.Grant(ctx, r.opts.leaseTTL())
+	if err != nil {
+		return 0, fmt.Errorf("create lease failed: %v", err)
+	}
+	if _, err = cli.Put(ctx, key, addr, clientv3.WithLease(resp.ID)); err != nil {
+		return 0, fmt.Errorf("add etcd record failed: %v", err)
+	}
+	return resp.ID, nil
+}
+
+// keepAlive drains the keepalive responses of lease, when they stop it
+// registers key again with a new lease until ctx is done
+func (r *EtcdRegistry) keepAlive(ctx context.Context, cli *clientv3.Client, lease *etcdLease, key, addr string, ch <-chan *clientv3.LeaseKeepAliveResponse) {
+	for {
+		for range ch {
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		r.logger.Warn("keepalive lost, register again", "key", key)
+
+		var (
+			id  clientv3.LeaseID
+			err error
+		)
+		for failures := 1; ; failures++ {
+			select {
+			case <-time.After(registerBackoff(failures)):
+			case <-ctx.Done():
+				return
+			}
+			putCtx, cancel := context.WithTimeout(ctx, r.opts.clientConfig().DialTimeout)
+			id, err = r.put(putCtx, cli, key, addr)
+			cancel()
+			if err == nil {
+				ch, err = cli.KeepAlive(ctx, id)
+			}
+			if err == nil {
+				break
+			}
+			r.logger.Warn("register again failed", "key", key, "err", err)
+		}
+
+		r.mu.Lock()
+		if ctx.Err() != nil {
+			// 重新注册的同时调用了 Deregister，撤销新的租约
+			r.mu.Unlock()
+			revokeCtx, cancel := context.WithTimeout(context.Background(), r.opts.clientConfig().DialTimeout)
+			cli.Revoke(revokeCtx, id)
+			cancel()
+			return
+		}
+		lease.id = id
+		r.mu.Unlock()
+		r.logger.Info("registered again", "key", key)
+	}
+}
+
+// registerBackoff doubles from minRegisterBackoff up to maxRegisterBackoff
+func registerBackoff(failures int) time.Duration {
+	backoff := minRegisterBackoff
+	for i := 1; i < failures && backoff < maxRegisterBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRegisterBackoff {
+		backoff = maxRegisterBackoff
+	}
+	return backoff
+}
+
+func (r *EtcdRegistry) Deregister(ctx context.Context, service, addr string) error {
+	key := r.serviceKey(service, addr)
+	r.mu.Lock()
+	lease, ok := r.leases[key]
+	if !ok {
+		r.mu.Unlock()
+		return nil
+	}
+	delete(r.leases, key)
+	// 持有锁时停止续约，之后不会再换新的租约
+	lease.cancel()
+	id := lease.id
+	r.mu.Unlock()
+
+	cli, err := r.client()
+	if err != nil {
+		return err
+	}
+	// 撤销租约，key 会随之删除
+	if _, err := cli.Revoke(ctx, id); err != nil {
+		return fmt.Errorf("revoke lease failed: %v", err)
+	}
+	return nil
+}
+
+func (r *EtcdRegistry) Resolve(ctx context.Context, service string) ([]string, error) {
+	cli, err := r.client()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cli.Get(ctx, r.servicePrefix(service), clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		addrs = append(addrs, string(kv.Value))
+	}
+	return addrs, nil
+}
+
+func (r *EtcdRegistry) Watch(ctx context.Context, service string) (<-chan Event, error) {
+	cli, err := r.client()
+	if err != nil {
+		return nil, err
+	}
+	prefix := r.servicePrefix(service)
+	resp, err := cli.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	// 从 Get 之后的版本开始 watch，不会漏掉变更
+	wch := cli.Watch(ctx, prefix, clientv3.WithPrefix(), clientv3.WithRev(resp.Header.Revision+1))
+
+	ch := make(chan Event)
+	go func() {
+		defer close(ch)
+		send := func(ev Event) bool {
+			select {
+			case ch <- ev:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		for _, kv := range resp.Kvs {
+			if !send(Event{Type: EventAdd, Addr: strings.TrimPrefix(string(kv.Key), prefix)}) {
+				return
+			}
+		}
+		for wresp := range wch {
+			for _, ev := range wresp.Events {
+				event := Event{Type: EventAdd, Addr: strings.TrimPrefix(string(ev.Kv.Key), prefix)}
+				if ev.Type == clientv3.EventTypeDelete {
+					event.Type = EventDelete
+				}
+				if !send(event) {
+					return
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// Close releases the etcd client
+func (r *EtcdRegistry) Close() error {
+	if r.cli != nil {
+		return r.cli.Close()
+	}
+	return nil
+}
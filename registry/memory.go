@@ -0,0 +1,124 @@
+package registry
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Memory is an in-process Registry, mainly for tests
+type Memory struct {
+	mu       sync.Mutex
+	services map[string]map[string]struct{}
+	watchers map[string]map[*memoryWatcher]struct{}
+}
+
+type memoryWatcher struct {
+	pending []Event       // protected by Memory.mu
+	notify  chan struct{} // 有新事件
+}
+
+// NewMemory creates an empty in-memory Registry
+func NewMemory() *Memory {
+	return &Memory{
+		services: make(map[string]map[string]struct{}),
+		watchers: make(map[string]map[*memoryWatcher]struct{}),
+	}
+}
+
+func (m *Memory) Register(ctx context.Context, service, addr string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	addrs, ok := m.services[service]
+	if !ok {
+		addrs = make(map[string]struct{})
+		m.services[service] = addrs
+	}
+	if _, ok := addrs[addr]; ok {
+		return nil
+	}
+	addrs[addr] = struct{}{}
+	m.broadcast(service, Event{Type: EventAdd, Addr: addr})
+	return nil
+}
+
+func (m *Memory) Deregister(ctx context.Context, service, addr string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.services[service][addr]; !ok {
+		return nil
+	}
+	delete(m.services[service], addr)
+	m.broadcast(service, Event{Type: EventDelete, Addr: addr})
+	return nil
+}
+
+func (m *Memory) Resolve(ctx context.Context, service string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.resolve(service), nil
+}
+
+// resolve must be called with m.mu held
+func (m *Memory) resolve(service string) []string {
+	addrs := make([]string, 0, len(m.services[service]))
+	for addr := range m.services[service] {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// broadcast must be called with m.mu held
+func (m *Memory) broadcast(service string, ev Event) {
+	for w := range m.watchers[service] {
+		w.pending = append(w.pending, ev)
+		select {
+		case w.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (m *Memory) Watch(ctx context.Context, service string) (<-chan Event, error) {
+	w := &memoryWatcher{notify: make(chan struct{}, 1)}
+	m.mu.Lock()
+	for _, addr := range m.resolve(service) {
+		w.pending = append(w.pending, Event{Type: EventAdd, Addr: addr})
+	}
+	w.notify <- struct{}{}
+	if m.watchers[service] == nil {
+		m.watchers[service] = make(map[*memoryWatcher]struct{})
+	}
+	m.watchers[service][w] = struct{}{}
+	m.mu.Unlock()
+
+	ch := make(chan Event)
+	go func() {
+		defer close(ch)
+		defer func() {
+			m.mu.Lock()
+			delete(m.watchers[service], w)
+			m.mu.Unlock()
+		}()
+		for {
+			select {
+			case <-w.notify:
+			case <-ctx.Done():
+				return
+			}
+			m.mu.Lock()
+			events := w.pending
+			w.pending = nil
+			m.mu.Unlock()
+			for _, ev := range events {
+				select {
+				case ch <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
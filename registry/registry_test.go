@@ -2,6 +2,7 @@ package registry
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"testing"
 	"time"
@@ -9,12 +10,31 @@ import (
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
+// requireEtcd skips the test when there is no etcd at 127.0.0.1:2379
+func requireEtcd(t *testing.T) {
+	t.Helper()
+	cli, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{"127.0.0.1:2379"},
+		DialTimeout: time.Second,
+	})
+	if err != nil {
+		t.Skipf("etcd not available: %v", err)
+	}
+	defer cli.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := cli.Status(ctx, "127.0.0.1:2379"); err != nil {
+		t.Skipf("etcd not available: %v", err)
+	}
+}
+
 func TestRegisterServiceToETCD(t *testing.T) {
+	requireEtcd(t)
 	stop := make(chan error)
 	erro := fmt.Errorf("stop")
 
 	go func() {
-		err := RegisterServiceToETCD("Hello", "127.0.0.1:8089", stop)
+		err := RegisterServiceToETCD("Hello", "127.0.0.1:8089", stop, nil)
 		if err != nil && err != erro {
 			t.Error(err)
 		}
@@ -43,4 +63,172 @@ func TestRegisterServiceToETCD(t *testing.T) {
 
 	time.Sleep(time.Second * 2)
 	stop <- erro
-}
\ No newline at end of file
+}
+
+func TestEtcdRegistry(t *testing.T) {
+	requireEtcd(t)
+	r := NewEtcdRegistry(EtcdOptions{
+		Endpoints: []string{"127.0.0.1:2379"},
+		KeyPrefix: "/registry-test/",
+		LeaseTTL:  3,
+	})
+	defer r.Close()
+	testRegistry(t, r, "etcd-registry-test")
+}
+
+func TestEtcdRegistryLeaseLost(t *testing.T) {
+	requireEtcd(t)
+	r := NewEtcdRegistry(EtcdOptions{
+		Endpoints: []string{"127.0.0.1:2379"},
+		KeyPrefix: "/registry-test/",
+		LeaseTTL:  3,
+	})
+	defer r.Close()
+	ctx := context.Background()
+	if err := r.Register(ctx, "etcd-lease-lost", "127.0.0.1:9001"); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Deregister(ctx, "etcd-lease-lost", "127.0.0.1:9001")
+
+	// 撤销租约模拟租约过期，key 被删除，续约中断
+	r.mu.Lock()
+	id := r.leases[r.serviceKey("etcd-lease-lost", "127.0.0.1:9001")].id
+	r.mu.Unlock()
+	cli, _ := r.client()
+	if _, err := cli.Revoke(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		addrs, err := r.Resolve(ctx, "etcd-lease-lost")
+		if err == nil && len(addrs) == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("addr was not registered again, resolved %v (err=%v)", addrs, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestRegisterBackoff(t *testing.T) {
+	if got := registerBackoff(1); got != minRegisterBackoff {
+		t.Fatalf("registerBackoff(1) = %v, want %v", got, minRegisterBackoff)
+	}
+	if got := registerBackoff(2); got != 2*minRegisterBackoff {
+		t.Fatalf("registerBackoff(2) = %v, want %v", got, 2*minRegisterBackoff)
+	}
+	if got := registerBackoff(100); got != maxRegisterBackoff {
+		t.Fatalf("registerBackoff(100) = %v, want %v", got, maxRegisterBackoff)
+	}
+}
+
+func TestEtcdOptions(t *testing.T) {
+	config := EtcdOptions{}.clientConfig()
+	if len(config.Endpoints) != 1 || config.Endpoints[0] != "127.0.0.1:2379" || config.DialTimeout != 5*time.Second {
+		t.Fatalf("unexpected defaults %+v", config)
+	}
+	if ttl := (EtcdOptions{}).leaseTTL(); ttl != 5 {
+		t.Fatalf("default lease ttl = %d", ttl)
+	}
+
+	tlsConfig := &tls.Config{ServerName: "etcd"}
+	opts := EtcdOptions{
+		Endpoints:   []string{"10.0.0.1:2379", "10.0.0.2:2379"},
+		DialTimeout: time.Second,
+		Username:    "root",
+		Password:    "secret",
+		TLS:         tlsConfig,
+		LeaseTTL:    10,
+		KeyPrefix:   "/prod/",
+	}
+	config = opts.clientConfig()
+	if len(config.Endpoints) != 2 || config.DialTimeout != time.Second ||
+		config.Username != "root" || config.Password != "secret" || config.TLS != tlsConfig {
+		t.Fatalf("options not applied %+v", config)
+	}
+	if opts.leaseTTL() != 10 {
+		t.Fatalf("lease ttl = %d", opts.leaseTTL())
+	}
+	r := NewEtcdRegistry(opts)
+	if key := r.serviceKey("groupcache", "127.0.0.1:8001"); key != "/prod/groupcache/127.0.0.1:8001" {
+		t.Fatalf("key = %s", key)
+	}
+}
+
+func TestMemoryRegistry(t *testing.T) {
+	testRegistry(t, NewMemory(), "memory")
+}
+
+func TestStaticRegistry(t *testing.T) {
+	r := NewStatic("127.0.0.1:8001", "127.0.0.1:8002")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	addrs, err := r.Resolve(ctx, "any")
+	if err != nil || len(addrs) != 2 {
+		t.Fatalf("Resolve = %v, %v", addrs, err)
+	}
+	ch, err := r.Watch(ctx, "any")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range addrs {
+		if ev := <-ch; ev.Type != EventAdd || ev.Addr != want {
+			t.Fatalf("got %+v, want add %s", ev, want)
+		}
+	}
+	cancel()
+	if _, open := <-ch; open {
+		t.Fatal("watch channel should be closed after ctx is done")
+	}
+}
+
+// testRegistry checks the behaviour every Registry should have
+func testRegistry(t *testing.T, r Registry, service string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := r.Register(ctx, service, "127.0.0.1:9001"); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Deregister(context.Background(), service, "127.0.0.1:9001")
+
+	ch, err := r.Watch(ctx, service)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := func(typ EventType, addr string) {
+		t.Helper()
+		select {
+		case ev := <-ch:
+			if ev.Type != typ || ev.Addr != addr {
+				t.Fatalf("got %+v, want %v %s", ev, typ, addr)
+			}
+		case <-ctx.Done():
+			t.Fatalf("timeout waiting for %v %s", typ, addr)
+		}
+	}
+	// 已注册的节点
+	expect(EventAdd, "127.0.0.1:9001")
+
+	if err := r.Register(ctx, service, "127.0.0.1:9002"); err != nil {
+		t.Fatal(err)
+	}
+	expect(EventAdd, "127.0.0.1:9002")
+
+	addrs, err := r.Resolve(ctx, service)
+	if err != nil || len(addrs) != 2 {
+		t.Fatalf("Resolve = %v, %v", addrs, err)
+	}
+
+	if err := r.Deregister(ctx, service, "127.0.0.1:9002"); err != nil {
+		t.Fatal(err)
+	}
+	expect(EventDelete, "127.0.0.1:9002")
+
+	addrs, err = r.Resolve(ctx, service)
+	if err != nil || len(addrs) != 1 || addrs[0] != "127.0.0.1:9001" {
+		t.Fatalf("Resolve after Deregister = %v, %v", addrs, err)
+	}
+}
@@ -0,0 +1,41 @@
+package registry
+
+import "context"
+
+// Static is a fixed list of addrs, Register and Deregister are no-ops
+type Static struct {
+	addrs []string
+}
+
+// NewStatic creates a Registry that always resolves to addrs
+func NewStatic(addrs ...string) *Static {
+	return &Static{addrs: append([]string(nil), addrs...)}
+}
+
+func (s *Static) Register(ctx context.Context, service, addr string) error {
+	return nil
+}
+
+func (s *Static) Deregister(ctx context.Context, service, addr string) error {
+	return nil
+}
+
+func (s *Static) Resolve(ctx context.Context, service string) ([]string, error) {
+	return append([]string(nil), s.addrs...), nil
+}
+
+func (s *Static) Watch(ctx context.Context, service string) (<-chan Event, error) {
+	ch := make(chan Event)
+	go func() {
+		defer close(ch)
+		for _, addr := range s.addrs {
+			select {
+			case ch <- Event{Type: EventAdd, Addr: addr}:
+			case <-ctx.Done():
+				return
+			}
+		}
+		<-ctx.Done()
+	}()
+	return ch, nil
+}
@@ -5,35 +5,54 @@ package mygroupcache
 import (
 	"context"
 	"fmt"
-	"log"
 	pb "my_groupcache/cachepb"
 	"my_groupcache/consistenthash"
+	"my_groupcache/logger"
 	"my_groupcache/registry"
 	"net"
+	"net/http"
 	"sync"
+	"time"
 
 	"google.golang.org/grpc"
-	"google.golang.org/protobuf/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type GRPCPool struct {
 	pb.UnimplementedCacheServiceServer
 	addr string // 服务地址
 	status bool // 是否启动
-	stopSignal chan error // 通知registry revoke服务
+	registry registry.Registry // 服务发现
+	service string // 注册到服务发现时使用的服务名
 	replicas int                     // 一致性哈希时，key 翻倍的倍数。如果为空，则默认为 50
 	hashFunc consistenthash.Hash
-	mu sync.Mutex
+	mu sync.RWMutex // 读多写少，Get 和 PickPeer 只加读锁
 	peers *consistenthash.Map
 	client map[string] *client
+	groups map[string]*Group // 通过 RegisterPeers 绑定到本节点的 group
+	dial func(addr string) (*grpc.ClientConn, error) // 为空时使用 defaultDial
+	conns *connManager // 到其他节点的连接，所有 group 共用
+	ctx context.Context // 注册和 watch 的生命周期
+	cancel context.CancelFunc
+	server *grpc.Server
+	abortCtx context.Context // Stop 超时后取消所有 handler 的 ctx
+	abort context.CancelFunc
+	registerDone chan struct{} // register 结束后关闭
+	stopped bool
+	peerMetrics *peerMetrics // 到其他节点请求的耗时
+	metricsAddr string // 为空时不启动 /metrics
+	metricsServer *http.Server
+	logger logger.Logger // 日志带有 addr 字段，默认不输出
 }
 
 const (
 	defaultAddr     = "127.0.0.1:8090"
 	defaultReplicas = 50
+	defaultService  = "groupcache"
 )
 
-func NewGRPCPool(addr string, replicas int, hashFunc consistenthash.Hash) *GRPCPool {
+func NewGRPCPool(addr string, replicas int, hashFunc consistenthash.Hash, opts ...PoolOption) *GRPCPool {
 	if addr == "" {
 		addr = defaultAddr
 	}
@@ -44,6 +63,18 @@ func NewGRPCPool(addr string, replicas int, hashFunc consistenthash.Hash) *GRPCP
 		addr: addr,
 		replicas: replicas,
 		hashFunc: hashFunc,
+		service: defaultService,
+		peerMetrics: newPeerMetrics(),
+	}
+	s.conns = newConnManager(s.dialPeer)
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.abortCtx, s.abort = context.WithCancel(context.Background())
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.logger = logger.With(s.logger, "addr", s.addr)
+	if s.registry == nil {
+		s.registry = registry.NewEtcdRegistry(registry.EtcdOptions{Logger: s.logger})
 	}
 	RegisterPeerPicker(s)
 	return s
@@ -51,7 +82,7 @@ func NewGRPCPool(addr string, replicas int, hashFunc consistenthash.Hash) *GRPCP
 }
 // Log info with server name
 func (p *GRPCPool) Log(format string, v ...interface{}) {
-	log.Printf("[Server %s] %s", p.addr, fmt.Sprintf(format, v...))
+	p.logger.Info(fmt.Sprintf(format, v...))
 }
 
 func (p *GRPCPool) Get(ctx context.Context, req *pb.Request) (response *pb.Response, err error) {
@@ -59,26 +90,56 @@ func (p *GRPCPool) Get(ctx context.Context, req *pb.Request) (response *pb.Respo
 	group_name := req.GetGroup()
 	key_name := req.GetKey()
 
-	group := GetGroup(group_name)
+	group := p.getGroup(group_name)
 	if group == nil {
-		log.Fatalf("No such group : %s", group_name)
-		return
+		return nil, toStatus(fmt.Errorf("%w: %s", ErrGroupNotFound, group_name))
 	}
-	mu.Lock()
-	defer mu.Unlock()
-	view, err := group.Get(key_name)
+	group.stats.serverRequests.Add(1)
+	view, err := group.GetContext(ctx, key_name)
 	if err != nil {
-		log.Fatal("Can't get data")
-		return
+		return nil, toStatus(err)
 	}
-	response = &pb.Response{}
-	// 压缩序列化
-	response.Value, err = proto.Marshal(&pb.Response{Value: view.ByteSlice()})
-	if err != nil {
-		log.Fatalf("Marshal err : %s", err.Error())
-		return
+	// 直接返回原始字节，grpc 会负责序列化
+	return &pb.Response{Value: view.ByteSlice()}, nil
+}
+
+// Set stores the value in the local cache of this peer
+func (p *GRPCPool) Set(ctx context.Context, req *pb.SetRequest) (*pb.Response, error) {
+	group := p.getGroup(req.GetGroup())
+	if group == nil {
+		return nil, toStatus(fmt.Errorf("%w: %s", ErrGroupNotFound, req.GetGroup()))
+	}
+	if req.GetKey() == "" {
+		return nil, toStatus(ErrKeyRequired)
+	}
+	ttl := time.Duration(req.GetTtl()) * time.Millisecond
+	if req.GetTtl() < 0 {
+		ttl = NoExpiration
+	}
+	group.setLocally(req.GetKey(), ByteView{b: req.GetValue()}, ttl)
+	return &pb.Response{}, nil
+}
+
+// Delete removes the key from the local cache of this peer
+func (p *GRPCPool) Delete(ctx context.Context, req *pb.Request) (*pb.ResponseForDelete, error) {
+	group := p.getGroup(req.GetGroup())
+	if group == nil {
+		return nil, toStatus(fmt.Errorf("%w: %s", ErrGroupNotFound, req.GetGroup()))
+	}
+	return &pb.ResponseForDelete{Value: group.deleteLocally(req.GetKey())}, nil
+}
+
+// GetBloomFilter ships the bloom filter of a group to another peer
+func (p *GRPCPool) GetBloomFilter(ctx context.Context, req *pb.BloomRequest) (*pb.BloomFilter, error) {
+	group := p.getGroup(req.GetGroup())
+	if group == nil {
+		return nil, toStatus(fmt.Errorf("%w: %s", ErrGroupNotFound, req.GetGroup()))
+	}
+	f := group.bloomFilter()
+	if f == nil {
+		return nil, status.Errorf(codes.Unavailable, "group %s has no bloom filter", req.GetGroup())
 	}
-	return
+	return &pb.BloomFilter{Bits: f.Bits(), K: f.K()}, nil
 }
 
 // start
@@ -89,55 +150,287 @@ func (p *GRPCPool) Start() error {
 		return fmt.Errorf("server can only be started once")
 	}
 	p.status = true
-	p.stopSignal = make(chan error)
 	p.mu.Unlock()
 
 	// 建议直接使用完整的监听地址
 	lis, err := net.Listen("tcp", p.addr)
 	if err != nil {
-		log.Printf("failed to listen: %v", err)
+		p.logger.Error("listen failed", "err", err)
 		return err
 	}
 
-	gs := grpc.NewServer()
+	return p.serve(lis)
+}
+
+// serve registers the pool to service discovery and blocks serving the cache service on lis
+func (p *GRPCPool) serve(lis net.Listener) error {
+	gs := grpc.NewServer(grpc.ChainUnaryInterceptor(p.abortable))
 	pb.RegisterCacheServiceServer(gs, p)
 
-	// 注册服务到 etcd（异步，不影响服务启动）
-	go func() {
-		if err := registry.RegisterServiceToETCD("groupcache", p.addr, p.stopSignal); err != nil {
-			log.Printf("etcd register error: %v", err)
+	p.mu.Lock()
+	if p.stopped {
+		p.mu.Unlock()
+		return grpc.ErrServerStopped
+	}
+	p.server = gs
+	if p.metricsAddr != "" && p.metricsServer == nil {
+		srv, err := p.serveMetrics(p.metricsAddr)
+		if err != nil {
+			p.mu.Unlock()
+			return err
 		}
+		p.metricsServer = srv
+	}
+	p.registerDone = make(chan struct{})
+	registerDone := p.registerDone
+	p.mu.Unlock()
+
+	// 注册服务并监听节点变化（异步，不影响服务启动）
+	go func() {
+		defer close(registerDone)
+		p.register()
 	}()
+	go p.watchPeers()
 
-	log.Printf("gRPC Server listening at %s", p.addr)
+	p.logger.Info("gRPC server listening")
 	if err := gs.Serve(lis); err != nil {
-		log.Printf("grpc serve error: %v", err)
+		p.logger.Error("gRPC serve failed", "err", err)
 		return err
 	}
 	return nil
 }
 
+// Stop deregisters this node from service discovery, waits for in-flight calls
+// to finish, then closes the peer connections and stops the groups of the pool.
+// Once ctx is done the contexts of the calls still running are canceled and
+// Stop returns ctx.Err() without waiting for them.
+func (p *GRPCPool) Stop(ctx context.Context) error {
+	p.mu.Lock()
+	if p.stopped {
+		p.mu.Unlock()
+		return nil
+	}
+	p.stopped = true
+	gs := p.server
+	registerDone := p.registerDone
+	metricsServer := p.metricsServer
+	p.mu.Unlock()
+
+	// 先从服务发现中摘除，其他节点不再把请求发过来
+	p.cancel()
+	if registerDone != nil {
+		select {
+		case <-registerDone:
+		case <-ctx.Done():
+		}
+	}
+	var err error
+	if derr := p.registry.Deregister(ctx, p.service, p.addr); derr != nil {
+		p.logger.Warn("deregister failed", "err", derr)
+		err = derr
+	}
+
+	// 等待处理中的请求结束
+	if gs != nil {
+		done := make(chan struct{})
+		go func() {
+			gs.GracefulStop()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			// 不再等待，取消仍在执行的 handler，它们会在后台结束
+			p.abort()
+			err = ctx.Err()
+		}
+	}
+
+	stopMetrics(ctx, metricsServer)
+	p.conns.close()
+	p.mu.RLock()
+	groups := p.groups
+	p.mu.RUnlock()
+	for _, g := range groups {
+		g.stop()
+	}
+	return err
+}
+
+// Close is Stop without a deadline
+func (p *GRPCPool) Close() error {
+	return p.Stop(context.Background())
+}
+
+// abortable cancels the ctx of the handler when Stop gives up waiting
+func (p *GRPCPool) abortable(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(p.abortCtx, cancel)
+	defer stop()
+	return handler(ctx, req)
+}
+
+// register announces this node to service discovery
+func (p *GRPCPool) register() {
+	if err := p.registry.Register(p.ctx, p.service, p.addr); err != nil {
+		p.logger.Error("register failed", "err", err)
+	}
+}
+
+// watchPeers keeps the ring in sync with service discovery until the pool is stopped
+func (p *GRPCPool) watchPeers() {
+	rewatch := false
+	for {
+		ch, err := p.registry.Watch(p.ctx, p.service)
+		if err != nil {
+			p.logger.Warn("watch peers failed", "err", err)
+		} else {
+			if rewatch {
+				// 重新 watch 只会重放现有的节点，中断期间离开的节点要主动删除
+				p.removeLeftPeers()
+			}
+			for ev := range ch {
+				switch ev.Type {
+				case registry.EventAdd:
+					p.addPeer(ev.Addr)
+				case registry.EventDelete:
+					p.removePeer(ev.Addr)
+				}
+			}
+		}
+		// watch 异常结束，稍后重试
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+		rewatch = true
+	}
+}
+
+// removeLeftPeers removes the peers that are no longer registered. It must be
+// called after a new watch is started, the watch adds the peers that joined
+// since and removes the ones that leave after Resolve.
+func (p *GRPCPool) removeLeftPeers() {
+	addrs, err := p.registry.Resolve(p.ctx, p.service)
+	if err != nil {
+		p.logger.Warn("resolve peers failed", "err", err)
+		return
+	}
+	registered := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		registered[addr] = true
+	}
+	p.mu.RLock()
+	var left []string
+	for addr := range p.client {
+		if !registered[addr] {
+			left = append(left, addr)
+		}
+	}
+	p.mu.RUnlock()
+	for _, addr := range left {
+		p.logger.Info("peer left while not watching", "peer", addr)
+		p.removePeer(addr)
+	}
+}
+
+// addGroup binds g to this pool, so that requests are served by it even if
+// another group with the same name exists in the process
+func (p *GRPCPool) addGroup(g *Group) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.groups == nil {
+		p.groups = make(map[string]*Group)
+	}
+	p.groups[g.name] = g
+}
+
+// getGroup prefers the groups bound to this pool, then the global ones
+func (p *GRPCPool) getGroup(name string) *Group {
+	p.mu.RLock()
+	g := p.groups[name]
+	p.mu.RUnlock()
+	if g != nil {
+		return g
+	}
+	return GetGroup(name)
+}
+
 
 func (p *GRPCPool) SetPeers(peers ...string) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	p.peers = consistenthash.New(defaultReplicas, p.hashFunc)
-	p.peers.Add(peers...)
+	old := p.client
+	p.peers = p.newRing()
 	p.client = make(map[string]*client, len(peers))
-	// 创建客户端
-	// groupcache/ip:port
 	for _, peer := range peers {
-		p.client[peer] = &client{name: "groupcache/" + peer}
+		p.addPeerLocked(peer)
+	}
+	// 关闭不再属于哈希环的节点的连接
+	for addr := range old {
+		if _, ok := p.client[addr]; !ok {
+			p.conns.remove(addr)
+		}
 	}
 }
 
-func (p *GRPCPool) PickPeer(key string) (PeerGetter, bool) {
+// addPeer adds a node to the ring, adding a known node is a no-op
+func (p *GRPCPool) addPeer(addr string) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	//log.Printf("PickPeer %s", p.peers.Get(key))
+	if p.peers == nil {
+		p.peers = p.newRing()
+		p.client = make(map[string]*client)
+	}
+	p.addPeerLocked(addr)
+}
+
+func (p *GRPCPool) newRing() *consistenthash.Map {
+	m := consistenthash.New(p.replicas, p.hashFunc)
+	m.SetLogger(p.logger)
+	return m
+}
+
+// addPeerLocked must be called with p.mu held
+func (p *GRPCPool) addPeerLocked(addr string) {
+	if _, ok := p.client[addr]; ok {
+		return
+	}
+	p.peers.Add(addr)
+	// 创建客户端
+	// groupcache/ip:port
+	p.client[addr] = &client{name: p.service + "/" + addr, addr: addr, conns: p.conns, metrics: p.peerMetrics}
+}
+
+// removePeer removes a node from the ring
+func (p *GRPCPool) removePeer(addr string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.client[addr]; !ok {
+		return
+	}
+	p.peers.Remove(addr)
+	delete(p.client, addr)
+	p.conns.remove(addr)
+}
+
+// dialPeer dials with the dial hook of the pool, or defaultDial
+func (p *GRPCPool) dialPeer(addr string) (*grpc.ClientConn, error) {
+	if p.dial != nil {
+		return p.dial(addr)
+	}
+	return defaultDial(addr)
+}
+
+func (p *GRPCPool) PickPeer(key string) (PeerGetter, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.peers == nil {
+		return nil, false
+	}
 	if peer := p.peers.Get(key); peer != "" && peer != p.addr {
-		log.Printf("[Server: %s] : Pick Peer: %s, request key: %s", p.addr, peer, key)
-		// log.Printf("Pick peer: %s", peer)
 		return p.client[peer], true
 	}
 	return nil, false
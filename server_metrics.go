@@ -0,0 +1,192 @@
+package mygroupcache
+
+import (
+	"context"
+	"my_groupcache/lru"
+	"my_groupcache/metrics"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// peerMetrics records the latency of the calls made to each peer
+type peerMetrics struct {
+	mu      sync.Mutex
+	latency map[peerMethod]*metrics.Histogram
+	errors  map[peerMethod]*atomic.Int64
+}
+
+type peerMethod struct {
+	peer, method string
+}
+
+func newPeerMetrics() *peerMetrics {
+	return &peerMetrics{
+		latency: make(map[peerMethod]*metrics.Histogram),
+		errors:  make(map[peerMethod]*atomic.Int64),
+	}
+}
+
+// observe records a call to peer that took d, nil m records nothing
+func (m *peerMetrics) observe(peer, method string, d time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	key := peerMethod{peer, method}
+	m.mu.Lock()
+	h, ok := m.latency[key]
+	if !ok {
+		h = metrics.NewHistogram(metrics.DefBuckets)
+		m.latency[key] = h
+		m.errors[key] = new(atomic.Int64)
+	}
+	errs := m.errors[key]
+	m.mu.Unlock()
+	h.Observe(d.Seconds())
+	if err != nil {
+		errs.Add(1)
+	}
+}
+
+func (m *peerMetrics) write(w *metrics.Writer) {
+	m.mu.Lock()
+	keys := make([]peerMethod, 0, len(m.latency))
+	for key := range m.latency {
+		keys = append(keys, key)
+	}
+	m.mu.Unlock()
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].peer != keys[j].peer {
+			return keys[i].peer < keys[j].peer
+		}
+		return keys[i].method < keys[j].method
+	})
+	for _, key := range keys {
+		m.mu.Lock()
+		h, errs := m.latency[key], m.errors[key]
+		m.mu.Unlock()
+		labels := []metrics.Label{{Name: "peer", Value: key.peer}, {Name: "method", Value: key.method}}
+		w.Histogram("groupcache_peer_request_duration_seconds", "Latency of gRPC calls to peers.", h.Snapshot(), labels...)
+		w.Counter("groupcache_peer_request_errors_total", "Failed gRPC calls to peers.", float64(errs.Load()), labels...)
+	}
+}
+
+// groupCounters are the counters of Stats exported as metrics
+var groupCounters = []struct {
+	name, help string
+	value      func(Stats) int64
+}{
+	{"groupcache_gets_total", "Get requests.", func(s Stats) int64 { return s.Gets }},
+	{"groupcache_group_cache_hits_total", "Gets served from mainCache or hotCache.", func(s Stats) int64 { return s.CacheHits }},
+	{"groupcache_stale_hits_total", "Gets served with an expired entry in its grace window.", func(s Stats) int64 { return s.StaleHits }},
+	{"groupcache_negative_hits_total", "Gets served from the negative cache.", func(s Stats) int64 { return s.NegativeHits }},
+	{"groupcache_bloom_rejects_total", "Loads rejected by the bloom filter.", func(s Stats) int64 { return s.BloomRejects }},
+	{"groupcache_loads_total", "Gets that missed the caches and the negative cache.", func(s Stats) int64 { return s.Loads }},
+	{"groupcache_loads_deduped_total", "Loads run after singleflight deduplication.", func(s Stats) int64 { return s.LoadsDeduped }},
+	{"groupcache_peer_loads_total", "Values fetched from peers.", func(s Stats) int64 { return s.PeerLoads }},
+	{"groupcache_peer_errors_total", "Failed fetches from peers.", func(s Stats) int64 { return s.PeerErrors }},
+	{"groupcache_local_loads_total", "Values loaded by the Getter.", func(s Stats) int64 { return s.LocalLoads }},
+	{"groupcache_local_load_errors_total", "Failed loads of the Getter.", func(s Stats) int64 { return s.LocalLoadErrs }},
+	{"groupcache_refreshes_total", "Background refreshes.", func(s Stats) int64 { return s.Refreshes }},
+	{"groupcache_server_requests_total", "Get requests received from peers.", func(s Stats) int64 { return s.ServerRequests }},
+}
+
+var cacheNames = []struct {
+	typ  CacheType
+	name string
+}{
+	{MainCache, "main"},
+	{HotCache, "hot"},
+	{NegativeCache, "negative"},
+}
+
+// writeGroupMetrics writes the stats of g, its caches and its singleflight
+func writeGroupMetrics(w *metrics.Writer, g *Group) {
+	group := metrics.Label{Name: "group", Value: g.name}
+	stats := g.Stats()
+	for _, c := range groupCounters {
+		w.Counter(c.name, c.help, float64(c.value(stats)), group)
+	}
+
+	calls, dups := g.loader.Stats()
+	w.Counter("groupcache_singleflight_calls_total", "Calls into singleflight.", float64(calls), group)
+	w.Counter("groupcache_singleflight_dedup_total", "Calls that waited on an in-flight load.", float64(dups), group)
+
+	for _, cn := range cacheNames {
+		c := g.cacheOf(cn.typ)
+		if c == nil {
+			continue
+		}
+		labels := []metrics.Label{group, {Name: "cache", Value: cn.name}}
+		s := c.stats()
+		w.Gauge("groupcache_cache_bytes", "Bytes held by the cache.", float64(s.Bytes), labels...)
+		w.Gauge("groupcache_cache_items", "Items held by the cache.", float64(s.Items), labels...)
+		w.Counter("groupcache_cache_gets_total", "Lookups of the cache.", float64(s.Gets), labels...)
+		w.Counter("groupcache_cache_hits_total", "Hits of the cache.", float64(s.Hits), labels...)
+		w.Counter("groupcache_cache_promotions_total", "Promotions from the history to the hot tier of the LRU-K cache.", float64(s.Promotions), labels...)
+
+		ls := c.lruStats()
+		for _, tier := range []struct {
+			name  string
+			stats lru.TierStats
+		}{{"history", ls.History}, {"hot", ls.Hot}} {
+			tl := append(labels[:2:2], metrics.Label{Name: "tier", Value: tier.name})
+			w.Gauge("groupcache_lru_bytes", "Bytes held by a tier of the cache, policies other than LRU-K only use the hot tier.", float64(tier.stats.Bytes), tl...)
+			w.Gauge("groupcache_lru_items", "Items held by a tier of the cache, policies other than LRU-K only use the hot tier.", float64(tier.stats.Items), tl...)
+			w.Counter("groupcache_lru_evictions_total", "Entries evicted from a tier of the cache, policies other than LRU-K only use the hot tier.", float64(tier.stats.Evictions), tl...)
+			w.Counter("groupcache_lru_expirations_total", "Expired entries removed from a tier of the cache, policies other than LRU-K only use the hot tier.", float64(tier.stats.Expirations), tl...)
+		}
+	}
+}
+
+// MetricsHandler serves the stats of the groups bound to the pool and the
+// latency of the calls to peers in the Prometheus text format
+func (p *GRPCPool) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		p.mu.RLock()
+		groups := make([]*Group, 0, len(p.groups))
+		for _, g := range p.groups {
+			groups = append(groups, g)
+		}
+		p.mu.RUnlock()
+		sort.Slice(groups, func(i, j int) bool { return groups[i].name < groups[j].name })
+
+		w := metrics.NewWriter()
+		for _, g := range groups {
+			writeGroupMetrics(w, g)
+		}
+		p.peerMetrics.write(w)
+		rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.WriteTo(rw)
+	})
+}
+
+// serveMetrics serves /metrics on addr until the pool is stopped
+func (p *GRPCPool) serveMetrics(addr string) (*http.Server, error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", p.MetricsHandler())
+	srv := &http.Server{Handler: mux}
+	go func() {
+		if err := srv.Serve(lis); err != nil && err != http.ErrServerClosed {
+			p.logger.Error("metrics serve failed", "err", err)
+		}
+	}()
+	return srv, nil
+}
+
+// stopMetrics shuts the metrics server down
+func stopMetrics(ctx context.Context, srv *http.Server) {
+	if srv == nil {
+		return
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		srv.Close()
+	}
+}
@@ -0,0 +1,167 @@
+package mygroupcache
+
+import (
+	"context"
+	"my_groupcache/registry"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func scrape(t *testing.T, h http.Handler) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	return rec.Body.String()
+}
+
+func TestMetricsHandler(t *testing.T) {
+	pool := NewGRPCPool("127.0.0.1:8001", 0, nil, WithRegistry(registry.NewMemory()))
+	g, err := NewGroupWithOptions("metrics", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("v"), nil
+	}), WithNegativeCache(time.Minute, 1<<10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.stop()
+	pool.addGroup(g)
+
+	g.Get("Tom")
+	g.Get("Tom")
+	g.Get("Tom")
+
+	body := scrape(t, pool.MetricsHandler())
+	for _, want := range []string{
+		"# TYPE groupcache_gets_total counter",
+		`groupcache_gets_total{group="metrics"} 3`,
+		`groupcache_loads_total{group="metrics"} 1`,
+		`groupcache_group_cache_hits_total{group="metrics"} 2`,
+		`groupcache_cache_hits_total{group="metrics",cache="main"} 2`,
+		`groupcache_singleflight_calls_total{group="metrics"} 1`,
+		`groupcache_cache_items{group="metrics",cache="main"} 1`,
+		`groupcache_cache_items{group="metrics",cache="negative"} 0`,
+		`groupcache_cache_promotions_total{group="metrics",cache="main"} 1`,
+		`groupcache_lru_items{group="metrics",cache="main",tier="history"} 0`,
+		`groupcache_lru_items{group="metrics",cache="main",tier="hot"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q", want)
+		}
+	}
+	if strings.Contains(body, `cache="hot"`) {
+		t.Errorf("hot cache is disabled but exported:\n%s", body)
+	}
+	checkLabelSets(t, body)
+}
+
+// checkLabelSets fails if a metric is exported with different label names,
+// or a family has more than one HELP line, sum() over it would be wrong
+func checkLabelSets(t *testing.T, body string) {
+	t.Helper()
+	labelSets := make(map[string]string)
+	helps := make(map[string]int)
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, "# HELP ") {
+			helps[strings.Fields(line)[2]]++
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, labels := line, ""
+		if i := strings.IndexByte(line, '{'); i >= 0 {
+			name, labels = line[:i], line[i+1:strings.IndexByte(line, '}')]
+		} else {
+			name = strings.Fields(line)[0]
+		}
+		var names []string
+		for _, l := range strings.Split(labels, ",") {
+			if l == "" || strings.HasPrefix(l, "le=") {
+				continue
+			}
+			names = append(names, l[:strings.IndexByte(l, '=')])
+		}
+		set := strings.Join(names, ",")
+		if prev, ok := labelSets[name]; ok && prev != set {
+			t.Errorf("%s is exported with labels {%s} and {%s}", name, prev, set)
+		}
+		labelSets[name] = set
+	}
+	for name, n := range helps {
+		if n > 1 {
+			t.Errorf("%s has %d HELP lines", name, n)
+		}
+	}
+}
+
+func TestClusterPeerMetrics(t *testing.T) {
+	tc := newTestCluster(t, "cluster-metrics", 2, func(key string) ([]byte, error) {
+		return []byte("v"), nil
+	})
+	var key, from string
+	for i := 0; key == ""; i++ {
+		k := string(rune('a' + i))
+		if owner := tc.owner(k); owner != tc.addrs[0] {
+			key, from = k, tc.addrs[0]
+		}
+	}
+	if _, err := tc.groups[from].Get(key); err != nil {
+		t.Fatal(err)
+	}
+	body := scrape(t, tc.pools[from].MetricsHandler())
+	for _, want := range []string{
+		`groupcache_peer_request_duration_seconds_bucket{peer="node-1",method="Get",le="+Inf"} 1`,
+		`groupcache_peer_request_duration_seconds_count{peer="node-1",method="Get"} 1`,
+		`groupcache_peer_request_errors_total{peer="node-1",method="Get"} 0`,
+		`groupcache_peer_loads_total{group="cluster-metrics"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q", want)
+		}
+	}
+}
+
+func TestMetricsAddr(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+
+	pool := NewGRPCPool("node-0", 0, nil, WithRegistry(registry.NewMemory()), WithMetricsAddr(addr))
+	grpcLis := bufconn.Listen(1 << 20)
+	go pool.serve(grpcLis)
+
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		if resp, err = http.Get("http://" + addr + "/metrics"); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+		t.Fatalf("unexpected response %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := pool.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := http.Get("http://" + addr + "/metrics"); err == nil {
+		t.Fatal("metrics still served after Stop")
+	}
+}
@@ -0,0 +1,46 @@
+package mygroupcache
+
+import (
+	"my_groupcache/logger"
+	"my_groupcache/registry"
+)
+
+// PoolOption configures a GRPCPool
+type PoolOption func(*GRPCPool)
+
+// WithRegistry sets the service discovery backend, etcd at 127.0.0.1:2379 by default
+func WithRegistry(r registry.Registry) PoolOption {
+	return func(p *GRPCPool) {
+		p.registry = r
+	}
+}
+
+// WithServiceName sets the name this pool registers under, "groupcache" by default
+func WithServiceName(service string) PoolOption {
+	return func(p *GRPCPool) {
+		p.service = service
+	}
+}
+
+// WithEtcd uses etcd configured by opts as the service discovery backend
+func WithEtcd(opts registry.EtcdOptions) PoolOption {
+	return func(p *GRPCPool) {
+		p.registry = registry.NewEtcdRegistry(opts)
+	}
+}
+
+// WithPoolLogger sets the logger of the pool and of its hash ring, it is also
+// used by the default etcd registry. Nothing is logged by default.
+func WithPoolLogger(l logger.Logger) PoolOption {
+	return func(p *GRPCPool) {
+		p.logger = l
+	}
+}
+
+// WithMetricsAddr serves the Prometheus metrics of the pool at /metrics on addr
+// while the pool is serving, see GRPCPool.MetricsHandler
+func WithMetricsAddr(addr string) PoolOption {
+	return func(p *GRPCPool) {
+		p.metricsAddr = addr
+	}
+}
@@ -1,8 +1,19 @@
 package mygroupcache
 
 import (
+	"context"
+	"fmt"
+	"my_groupcache/bloom"
+	pb "my_groupcache/cachepb"
+	"my_groupcache/registry"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 func Test_PeerRelation(t *testing.T) {
@@ -25,4 +36,257 @@ func Test_PeerRelation(t *testing.T) {
 	if peer.(*client).name != "groupcache/127.0.0.1:8003" {
 		t.Errorf("pick wrong peer")
 	}
-}
\ No newline at end of file
+}
+func TestGRPCPoolSetDelete(t *testing.T) {
+	NewGroup("pool-set", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return nil, fmt.Errorf("%s not exist", key)
+	}))
+	s := NewGRPCPool("127.0.0.1:8001", 0, nil)
+
+	_, err := s.Set(context.Background(), &pb.SetRequest{Group: "pool-set", Key: "Sanji", Value: []byte("42"), Ttl: 60000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := s.Get(context.Background(), &pb.Request{Group: "pool-set", Key: "Sanji"})
+	if err != nil || resp == nil {
+		t.Fatalf("Get after Set failed: %v", err)
+	}
+
+	del, err := s.Delete(context.Background(), &pb.Request{Group: "pool-set", Key: "Sanji"})
+	if err != nil || !del.GetValue() {
+		t.Fatalf("expected Sanji to be deleted, got %v (err=%v)", del, err)
+	}
+	del, err = s.Delete(context.Background(), &pb.Request{Group: "pool-set", Key: "Sanji"})
+	if err != nil || del.GetValue() {
+		t.Fatalf("second Delete should report missing key, got %v (err=%v)", del, err)
+	}
+
+	// ttl 为 -1 时不过期
+	if _, err := NewGroupWithOptions("pool-set-no-expiration", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return nil, fmt.Errorf("%s not exist", key)
+	}), WithDefaultTTL(50*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Set(context.Background(), &pb.SetRequest{Group: "pool-set-no-expiration", Key: "Brook", Value: []byte("1"), Ttl: -1}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, err := s.Get(context.Background(), &pb.Request{Group: "pool-set-no-expiration", Key: "Brook"}); err != nil {
+		t.Fatalf("Brook was set without expiration: %v", err)
+	}
+
+	if _, err := s.Set(context.Background(), &pb.SetRequest{Group: "no-such-group", Key: "k"}); err == nil {
+		t.Fatal("expected error for unknown group")
+	}
+}
+
+func TestGRPCPoolGetMetadata(t *testing.T) {
+	var tenant []string
+	NewGroup("pool-metadata", 2<<10, ContextGetterFunc(func(ctx context.Context, key string) ([]byte, error) {
+		tenant = MetadataFromContext(ctx).Get("tenant")
+		return []byte("v"), nil
+	}))
+	s := NewGRPCPool("127.0.0.1:8001", 0, nil)
+
+	// grpc server 收到的是 incoming metadata
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("tenant", "t2"))
+	if _, err := s.Get(ctx, &pb.Request{Group: "pool-metadata", Key: "k"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(tenant) != 1 || tenant[0] != "t2" {
+		t.Fatalf("metadata should reach the getter on the owning peer, got %v", tenant)
+	}
+}
+
+func TestGRPCPoolGetErrors(t *testing.T) {
+	NewGroup("pool-errors", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}))
+	s := NewGRPCPool("127.0.0.1:8001", 0, nil)
+
+	_, err := s.Get(context.Background(), &pb.Request{Group: "no-such-group", Key: "k"})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition for a missing group, got %v", err)
+	}
+	_, err = s.Get(context.Background(), &pb.Request{Group: "pool-errors", Key: "k"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound for a missing key, got %v", err)
+	}
+	_, err = s.Get(context.Background(), &pb.Request{Group: "pool-errors", Key: ""})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for an empty key, got %v", err)
+	}
+}
+
+func TestGRPCPoolWatchPeers(t *testing.T) {
+	reg := registry.NewMemory()
+	reg.Register(context.Background(), "groupcache", "127.0.0.1:8002")
+	s := NewGRPCPool("127.0.0.1:8001", 0, nil, WithRegistry(reg))
+	defer s.cancel()
+	if _, ok := s.PickPeer("tom"); ok {
+		t.Fatal("no peers yet, PickPeer should fail")
+	}
+
+	// 没有调用 SetPeers，节点全部来自服务发现
+	s.register()
+	go s.watchPeers()
+	waitPeers(t, s, "127.0.0.1:8001", "127.0.0.1:8002")
+
+	reg.Register(context.Background(), "groupcache", "127.0.0.1:8003")
+	waitPeers(t, s, "127.0.0.1:8001", "127.0.0.1:8002", "127.0.0.1:8003")
+
+	reg.Deregister(context.Background(), "groupcache", "127.0.0.1:8002")
+	waitPeers(t, s, "127.0.0.1:8001", "127.0.0.1:8003")
+	for i := 0; i < 100; i++ {
+		if peer, ok := s.PickPeer(strconv.Itoa(i)); ok && peer.(*client).addr == "127.0.0.1:8002" {
+			t.Fatalf("key %d still picks the removed peer", i)
+		}
+	}
+}
+
+// breakableRegistry is a Memory registry whose watches can be ended, like an
+// etcd watch that is compacted or loses its connection
+type breakableRegistry struct {
+	*registry.Memory
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+func (r *breakableRegistry) Watch(ctx context.Context, service string) (<-chan registry.Event, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	r.mu.Lock()
+	r.cancel = cancel
+	r.mu.Unlock()
+	return r.Memory.Watch(ctx, service)
+}
+
+// breakWatch ends the current watch
+func (r *breakableRegistry) breakWatch() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cancel()
+}
+
+func TestGRPCPoolRewatchRemovesLeftPeers(t *testing.T) {
+	reg := &breakableRegistry{Memory: registry.NewMemory()}
+	reg.Register(context.Background(), "groupcache", "127.0.0.1:8002")
+	reg.Register(context.Background(), "groupcache", "127.0.0.1:8003")
+	s := NewGRPCPool("127.0.0.1:8001", 0, nil, WithRegistry(reg))
+	defer s.cancel()
+	s.register()
+	go s.watchPeers()
+	waitPeers(t, s, "127.0.0.1:8001", "127.0.0.1:8002", "127.0.0.1:8003")
+
+	// watch 中断期间 8002 离开，8004 加入
+	reg.breakWatch()
+	reg.Deregister(context.Background(), "groupcache", "127.0.0.1:8002")
+	reg.Register(context.Background(), "groupcache", "127.0.0.1:8004")
+	waitPeers(t, s, "127.0.0.1:8001", "127.0.0.1:8003", "127.0.0.1:8004")
+}
+
+// waitPeers waits until the ring of p contains exactly addrs
+func waitPeers(t *testing.T, p *GRPCPool, addrs ...string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		p.mu.Lock()
+		ok := len(p.client) == len(addrs)
+		for _, addr := range addrs {
+			if _, found := p.client[addr]; !found {
+				ok = false
+			}
+		}
+		p.mu.Unlock()
+		if ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%s: peers never became %v", p.addr, addrs)
+}
+
+func TestGRPCPoolGetNegativeCache(t *testing.T) {
+	loads := 0
+	g, err := NewGroupWithOptions("pool-negative", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}), WithNegativeCache(time.Minute, 1<<10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.stop()
+	s := NewGRPCPool("127.0.0.1:8001", 0, nil)
+
+	for i := 0; i < 2; i++ {
+		_, err := s.Get(context.Background(), &pb.Request{Group: "pool-negative", Key: "k"})
+		if status.Code(err) != codes.NotFound {
+			t.Fatalf("expected NotFound for a cached miss, got %v", err)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("cached miss should not reach the getter, loads = %d", loads)
+	}
+}
+
+func TestGRPCPoolGetBloomFilter(t *testing.T) {
+	g, err := NewGroupWithOptions("pool-bloom", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("v"), nil
+	}), WithBloomFilter(BloomOptions{Keys: func(ctx context.Context, add func(string)) error {
+		add("Tom")
+		return nil
+	}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.stop()
+	waitBloom(t, g)
+	s := NewGRPCPool("127.0.0.1:8001", 0, nil)
+
+	resp, err := s.GetBloomFilter(context.Background(), &pb.BloomRequest{Group: "pool-bloom"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := bloom.FromBits(resp.GetBits(), resp.GetK())
+	if err != nil || !f.Test("Tom") {
+		t.Fatalf("shipped filter should contain Tom, err = %v", err)
+	}
+	if _, err := s.GetBloomFilter(context.Background(), &pb.BloomRequest{Group: "no-such-group"}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition for a missing group, got %v", err)
+	}
+}
+
+// BenchmarkGRPCPoolGetParallel serves cached keys from every goroutine, run it
+// with -cpu 1,2,4,8 to compare one shard with the default number of shards
+func BenchmarkGRPCPoolGetParallel(b *testing.B) {
+	const keys = 1024
+	s := NewGRPCPool("127.0.0.1:8001", 0, nil)
+	for _, shards := range []int{1, 0} {
+		name := fmt.Sprintf("pool-bench-shards-%d", shards)
+		_, err := NewGroupWithOptions(name, 64<<20, GetterFunc(func(key string) ([]byte, error) {
+			return []byte("value"), nil
+		}), WithShards(shards), WithDefaultTTL(NoExpiration))
+		if err != nil {
+			b.Fatal(err)
+		}
+		for i := 0; i < keys; i++ {
+			s.Set(context.Background(), &pb.SetRequest{Group: name, Key: "key" + strconv.Itoa(i), Value: []byte("value")})
+		}
+		label := "shards=" + strconv.Itoa(shards)
+		if shards == 0 {
+			label = "shards=auto"
+		}
+		b.Run(label, func(b *testing.B) {
+			b.RunParallel(func(p *testing.PB) {
+				i := 0
+				for p.Next() {
+					req := &pb.Request{Group: name, Key: "key" + strconv.Itoa(i%keys)}
+					if _, err := s.Get(context.Background(), req); err != nil {
+						b.Error(err)
+						return
+					}
+					i++
+				}
+			})
+		})
+	}
+}
@@ -1,39 +1,185 @@
 package singleflight
 
-import "sync"
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
 type call struct {
-	wg  sync.WaitGroup
-	val interface{}
-	err error
+	done chan struct{} // closed when fn returns
+	val  interface{}
+	err  error
+
+	panicked bool        // fn panicked with panicValue
+	panicVal interface{} // re-panicked in every waiter
+
+	waiters int          // callers still waiting, protected by Group.mu
+	ctx     *callContext // ctx of fn
+}
+
+// callContext is the ctx of a shared call. It carries the values of the first
+// caller, its deadline is the latest deadline among the waiters, or none once
+// a waiter without a deadline joins
+type callContext struct {
+	context.Context // values of the first caller, never done
+
+	mu        sync.Mutex
+	deadline  time.Time
+	unbounded bool // a waiter has no deadline
+	timer     *time.Timer
+	done      chan struct{}
+	err       error
+}
+
+func newCallContext(ctx context.Context) *callContext {
+	return &callContext{Context: context.WithoutCancel(ctx), done: make(chan struct{})}
+}
+
+func (c *callContext) Deadline() (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.deadline, !c.deadline.IsZero()
+}
+
+func (c *callContext) Done() <-chan struct{} { return c.done }
+
+func (c *callContext) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// join extends the deadline to the one of ctx
+func (c *callContext) join(ctx context.Context) {
+	d, ok := ctx.Deadline()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil || c.unbounded {
+		return
+	}
+	if !ok {
+		// 有调用方不限时，加载也不再限时
+		c.unbounded = true
+		c.deadline = time.Time{}
+		if c.timer != nil {
+			c.timer.Stop()
+		}
+		return
+	}
+	if !d.After(c.deadline) {
+		return
+	}
+	c.deadline = d
+	if c.timer == nil {
+		c.timer = time.AfterFunc(time.Until(d), c.expire)
+	} else {
+		c.timer.Reset(time.Until(d))
+	}
+}
+
+// expire cancels c once its deadline has passed
+func (c *callContext) expire() {
+	c.mu.Lock()
+	// 定时器触发后 deadline 可能又被延长了
+	late := c.unbounded || time.Now().Before(c.deadline)
+	c.mu.Unlock()
+	if !late {
+		c.cancel(context.DeadlineExceeded)
+	}
+}
+
+func (c *callContext) cancel(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	close(c.done)
 }
 
 type Group struct {
 	mu sync.Mutex       // protects m
 	m  map[string]*call
+
+	calls atomic.Int64 // all calls of Do and DoContext
+	dups  atomic.Int64 // calls that waited on an in-flight call
+}
+
+// Stats returns the number of calls and how many of them were deduplicated
+func (g *Group) Stats() (calls, dups int64) {
+	return g.calls.Load(), g.dups.Load()
 }
 
 func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	return g.DoContext(context.Background(), key, func(context.Context) (interface{}, error) {
+		return fn()
+	})
+}
+
+// DoContext is like Do, fn runs with the values of the first caller's ctx and
+// the latest deadline among the callers, and is only canceled once every caller
+// waiting on it has given up, so a caller that cancels doesn't fail the others.
+// Callers return ctx.Err() once their own ctx is done. If fn panics, every
+// caller waiting on it panics with the same value.
+func (g *Group) DoContext(ctx context.Context, key string, fn func(context.Context) (interface{}, error)) (interface{}, error) {
+	g.calls.Add(1)
 	g.mu.Lock()
 	if g.m == nil {
 		g.m = make(map[string]*call)
 	}
-	if c, ok := g.m[key]; ok {
-		g.mu.Unlock()
-		c.wg.Wait()
-		return c.val, c.err
+	c, ok := g.m[key]
+	if ok {
+		g.dups.Add(1)
+		c.waiters++
+		c.ctx.join(ctx)
+	} else {
+		c = &call{done: make(chan struct{}), waiters: 1, ctx: newCallContext(ctx)}
+		c.ctx.join(ctx)
+		g.m[key] = c
+		go g.run(key, c, fn)
 	}
-	c := new(call)
-	c.wg.Add(1)
-	g.m[key] = c
 	g.mu.Unlock()
 
-	c.val, c.err = fn()
-	c.wg.Done()
-
-	g.mu.Lock()
-	delete(g.m, key)
-	g.mu.Unlock()
+	select {
+	case <-c.done:
+		if c.panicked {
+			panic(c.panicVal)
+		}
+		return c.val, c.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		c.waiters--
+		if c.waiters == 0 {
+			// 没有人等待结果了，取消加载，之后的调用重新开始
+			c.ctx.cancel(context.Canceled)
+			if g.m[key] == c {
+				delete(g.m, key)
+			}
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
 
-	return c.val, c.err
-}
\ No newline at end of file
+func (g *Group) run(key string, c *call, fn func(context.Context) (interface{}, error)) {
+	defer func() {
+		// fn 在单独的 goroutine 中运行，panic 交给等待的调用方重新抛出
+		if r := recover(); r != nil {
+			c.panicked, c.panicVal = true, r
+		}
+		g.mu.Lock()
+		if g.m[key] == c {
+			delete(g.m, key)
+		}
+		g.mu.Unlock()
+		c.ctx.cancel(context.Canceled)
+		close(c.done)
+	}()
+	c.val, c.err = fn(c.ctx)
+}
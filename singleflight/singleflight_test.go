@@ -0,0 +1,230 @@
+package singleflight
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDo(t *testing.T) {
+	var g Group
+	v, err := g.Do("key", func() (interface{}, error) {
+		return "bar", nil
+	})
+	if v.(string) != "bar" || err != nil {
+		t.Errorf("Do v = %v, error = %v", v, err)
+	}
+}
+
+func TestDoDedup(t *testing.T) {
+	var g Group
+	var calls int32
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.Do("key", func() (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				<-release
+				return "bar", nil
+			})
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("number of calls = %d; want 1", got)
+	}
+	if calls, dups := g.Stats(); calls != 10 || dups != 9 {
+		t.Errorf("Stats() = %d, %d; want 10, 9", calls, dups)
+	}
+}
+
+func TestDoContextWaiterCancel(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	defer close(release)
+	go g.DoContext(context.Background(), "key", func(context.Context) (interface{}, error) {
+		<-release
+		return "bar", nil
+	})
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := g.DoContext(ctx, "key", func(context.Context) (interface{}, error) {
+		t.Error("fn should not run while a call is in flight")
+		return nil, nil
+	})
+	if err != context.DeadlineExceeded {
+		t.Errorf("waiter err = %v; want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestDoContextPassesCtx(t *testing.T) {
+	var g Group
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "trace-1")
+	v, _ := g.DoContext(ctx, "key", func(ctx context.Context) (interface{}, error) {
+		return ctx.Value(ctxKey{}), nil
+	})
+	if v != "trace-1" {
+		t.Errorf("fn got ctx value %v; want trace-1", v)
+	}
+}
+
+func TestDoContextLeaderCancel(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error, 1)
+	go func() {
+		_, err := g.DoContext(ctx, "key", func(ctx context.Context) (interface{}, error) {
+			select {
+			case <-release:
+				return "bar", nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		})
+		leader <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	follower := make(chan interface{}, 1)
+	go func() {
+		v, err := g.DoContext(context.Background(), "key", func(context.Context) (interface{}, error) {
+			t.Error("fn should not run while a call is in flight")
+			return nil, nil
+		})
+		if err != nil {
+			t.Errorf("follower err = %v", err)
+		}
+		follower <- v
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// 第一个调用方放弃，不影响仍在等待的调用方
+	cancel()
+	if err := <-leader; err != context.Canceled {
+		t.Errorf("leader err = %v; want %v", err, context.Canceled)
+	}
+	close(release)
+	if v := <-follower; v != "bar" {
+		t.Errorf("follower got %v; want bar", v)
+	}
+}
+
+func TestDoContextAllCancel(t *testing.T) {
+	var g Group
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan struct{})
+	go g.DoContext(ctx, "key", func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		close(canceled)
+		return nil, ctx.Err()
+	})
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("fn should be canceled once every caller has given up")
+	}
+	// 之后的调用重新加载
+	v, err := g.Do("key", func() (interface{}, error) { return "new", nil })
+	if v != "new" || err != nil {
+		t.Errorf("Do after cancel = %v, %v", v, err)
+	}
+}
+
+func TestDoContextDeadline(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	deadlines := make(chan context.Context, 1)
+	leaderCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	go g.DoContext(leaderCtx, "key", func(ctx context.Context) (interface{}, error) {
+		deadlines <- ctx
+		<-release
+		return nil, nil
+	})
+	ctx := <-deadlines
+	want, _ := leaderCtx.Deadline()
+	if d, ok := ctx.Deadline(); !ok || !d.Equal(want) {
+		t.Fatalf("Deadline() = %v, %v; want the caller's %v", d, ok, want)
+	}
+
+	join := func(ctx context.Context) {
+		go g.DoContext(ctx, "key", nil)
+		time.Sleep(50 * time.Millisecond)
+	}
+	// 取所有调用方中最晚的 deadline
+	later, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	join(later)
+	want, _ = later.Deadline()
+	if d, ok := ctx.Deadline(); !ok || !d.Equal(want) {
+		t.Fatalf("Deadline() = %v, %v; want the latest %v", d, ok, want)
+	}
+	sooner, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	join(sooner)
+	if d, _ := ctx.Deadline(); !d.Equal(want) {
+		t.Fatalf("Deadline() = %v; a sooner caller should not shorten it", d)
+	}
+	// 有调用方不限时，加载也不限时
+	join(context.Background())
+	if d, ok := ctx.Deadline(); ok {
+		t.Fatalf("Deadline() = %v; want none", d)
+	}
+	close(release)
+}
+
+func TestDoContextDeadlineExceeded(t *testing.T) {
+	var g Group
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := g.DoContext(ctx, "key", func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if err != context.DeadlineExceeded {
+		t.Errorf("err = %v; want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestDoContextPanic(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	recovered := make(chan interface{}, 2)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { recovered <- recover() }()
+			g.DoContext(context.Background(), "key", func(context.Context) (interface{}, error) {
+				<-release
+				panic("boom")
+			})
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	// 每个等待的调用方都能 recover 到同一个 panic
+	for i := 0; i < 2; i++ {
+		if r := <-recovered; r != "boom" {
+			t.Errorf("recover() = %v; want boom", r)
+		}
+	}
+	if v, err := g.Do("key", func() (interface{}, error) { return "ok", nil }); v != "ok" || err != nil {
+		t.Errorf("Do after panic = %v, %v", v, err)
+	}
+}
@@ -0,0 +1,102 @@
+package mygroupcache
+
+import "sync/atomic"
+
+// Stats are the counters of a group
+type Stats struct {
+	Gets           int64 // 所有 Get 请求
+	CacheHits      int64 // mainCache 或 hotCache 命中
+	StaleHits      int64 // 命中过期但仍在宽限期内的数据
+	NegativeHits   int64 // 命中 negative cache
+	BloomRejects   int64 // 被布隆过滤器拦截
+	Loads          int64 // 缓存和 negative cache 都未命中的加载 (gets - cacheHits - negativeHits)，包括被布隆过滤器拦截的
+	LoadsDeduped   int64 // singleflight 合并后实际执行的加载
+	PeerLoads      int64 // 从其他节点获取成功
+	PeerErrors     int64 // 从其他节点获取失败
+	LocalLoads     int64 // Getter 加载成功
+	LocalLoadErrs  int64 // Getter 加载失败
+	Refreshes      int64 // 后台刷新（过期后刷新和提前刷新）
+	ServerRequests int64 // 其他节点发来的 Get 请求
+}
+
+// CacheStats are the stats of one of the caches of a group
+type CacheStats struct {
+	Bytes       int64
+	Items       int64
+	Gets        int64
+	Hits        int64
+	Evictions   int64
+	Expirations int64
+	Promotions  int64 // LRU-K 从 history 晋升到热点层的次数
+}
+
+// CacheType selects a cache of a group in Group.CacheStats
+type CacheType int
+
+const (
+	// MainCache holds the keys this node owns
+	MainCache CacheType = iota + 1
+	// HotCache holds the sampled values fetched from peers
+	HotCache
+	// NegativeCache holds the keys the Getter reported as not found
+	NegativeCache
+)
+
+// groupStats are the atomic counters behind Stats
+type groupStats struct {
+	gets           atomic.Int64
+	cacheHits      atomic.Int64
+	staleHits      atomic.Int64
+	negativeHits   atomic.Int64
+	bloomRejects   atomic.Int64
+	loads          atomic.Int64
+	loadsDeduped   atomic.Int64
+	peerLoads      atomic.Int64
+	peerErrors     atomic.Int64
+	localLoads     atomic.Int64
+	localLoadErrs  atomic.Int64
+	refreshes      atomic.Int64
+	serverRequests atomic.Int64
+}
+
+// Stats returns a snapshot of the counters of the group
+func (g *Group) Stats() Stats {
+	s := &g.stats
+	return Stats{
+		Gets:           s.gets.Load(),
+		CacheHits:      s.cacheHits.Load(),
+		StaleHits:      s.staleHits.Load(),
+		NegativeHits:   s.negativeHits.Load(),
+		BloomRejects:   s.bloomRejects.Load(),
+		Loads:          s.loads.Load(),
+		LoadsDeduped:   s.loadsDeduped.Load(),
+		PeerLoads:      s.peerLoads.Load(),
+		PeerErrors:     s.peerErrors.Load(),
+		LocalLoads:     s.localLoads.Load(),
+		LocalLoadErrs:  s.localLoadErrs.Load(),
+		Refreshes:      s.refreshes.Load(),
+		ServerRequests: s.serverRequests.Load(),
+	}
+}
+
+// CacheStats returns the stats of one of the caches of the group, a cache
+// that is not enabled has zero stats
+func (g *Group) CacheStats(which CacheType) CacheStats {
+	if c := g.cacheOf(which); c != nil {
+		return c.stats()
+	}
+	return CacheStats{}
+}
+
+// cacheOf returns the selected cache of the group, nil if it is not enabled
+func (g *Group) cacheOf(which CacheType) *cache {
+	switch which {
+	case MainCache:
+		return &g.mainCache
+	case HotCache:
+		return g.hotCache
+	case NegativeCache:
+		return g.negativeCache
+	}
+	return nil
+}
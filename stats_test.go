@@ -0,0 +1,82 @@
+package mygroupcache
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestGroupStats(t *testing.T) {
+	g, err := NewGroupWithOptions("stats", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		if v, ok := db[key]; ok {
+			return []byte(v), nil
+		}
+		if key == "flaky" {
+			return nil, errors.New("db down")
+		}
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}), WithNegativeCache(time.Minute, 1<<10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.stop()
+
+	g.Get("Tom")   // load
+	g.Get("Tom")   // hit, 晋升到热点层
+	g.Get("Tom")   // hit
+	g.Get("Kaido") // load, not found
+	g.Get("Kaido") // negative hit
+	g.Get("flaky") // load error
+	want := Stats{Gets: 6, CacheHits: 2, NegativeHits: 1, Loads: 3, LoadsDeduped: 3, LocalLoads: 1, LocalLoadErrs: 2}
+	if got := g.Stats(); got != want {
+		t.Fatalf("Stats() = %+v, want %+v", got, want)
+	}
+
+	main := g.CacheStats(MainCache)
+	if main.Items != 1 || main.Bytes != int64(len("Tom")+len("630")) || main.Hits != 2 || main.Gets != 6 || main.Promotions != 1 {
+		t.Fatalf("unexpected main cache stats: %+v", main)
+	}
+	if neg := g.CacheStats(NegativeCache); neg.Items != 1 || neg.Hits != 1 {
+		t.Fatalf("unexpected negative cache stats: %+v", neg)
+	}
+	if hot := g.CacheStats(HotCache); hot != (CacheStats{}) {
+		t.Fatalf("hot cache is disabled, got %+v", hot)
+	}
+}
+
+func TestGroupStatsPeer(t *testing.T) {
+	g, err := NewGroupWithOptions("stats-peer", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("local"), nil
+	}), WithHotCacheBytes(1<<10), WithHotCacheSampleRate(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.stop()
+	g.RegisterPeers(fakePicker{peer: &fakePeer{sets: map[string][]byte{"Tom": []byte("630")}}})
+
+	g.Get("Tom")  // peer load
+	g.Get("Tom")  // hot cache hit
+	g.Get("Jack") // peer error, 本地加载
+	s := g.Stats()
+	if s.PeerLoads != 1 || s.PeerErrors != 1 || s.LocalLoads != 1 || s.CacheHits != 1 {
+		t.Fatalf("unexpected stats: %+v", s)
+	}
+	if hot := g.CacheStats(HotCache); hot.Items != 1 || hot.Hits != 1 {
+		t.Fatalf("unexpected hot cache stats: %+v", hot)
+	}
+}
+
+func TestCacheStatsEvictions(t *testing.T) {
+	c := &cache{maxBytes: 20, k: 2}
+	defer c.close()
+	c.add("a", ByteView{b: []byte("123456789")}, 0)
+	c.add("b", ByteView{b: []byte("123456789")}, 0) // 超过 20 字节，淘汰 a
+	c.add("c", ByteView{b: []byte("1")}, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	c.get("c") // 过期
+	s := c.stats()
+	if s.Evictions != 1 || s.Expirations != 1 || s.Items != 1 {
+		t.Fatalf("unexpected cache stats: %+v", s)
+	}
+}